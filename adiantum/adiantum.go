@@ -0,0 +1,189 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// Package adiantum implements the Adiantum and HPolyC length-preserving
+// encryption modes.
+//
+// Adiantum and HPolyC are tweakable, wide-block ciphers built from
+// XChaCha12, AES-256 and a Poly1305-based hash. A message of at least 16
+// bytes is encrypted as a single block - so changing any bit of the
+// plaintext changes the entire ciphertext. Both modes don't expand the
+// ciphertext and are intended for disk sectors and other fixed-size
+// records. They don't provide authenticity - use an AEAD if the ciphertext
+// can grow.
+//
+// Adiantum and HPolyC are specified in https://eprint.iacr.org/2018/720.
+package adiantum // import "github.com/aead/chacha20/adiantum"
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"github.com/aead/chacha20/chacha"
+)
+
+// BlockSize is the minimum length of a message in bytes.
+const BlockSize = aes.BlockSize
+
+var errKeySize = errors.New("adiantum: bad key length")
+
+// tweakableHash computes the 128 bit hash of a message and a tweak.
+type tweakableHash interface {
+	sum(out *[16]byte, msg, tweak []byte)
+}
+
+// Cipher implements the HBSH construction ("hash, block cipher,
+// stream cipher, hash") used by Adiantum and HPolyC.
+type Cipher struct {
+	key    [32]byte
+	rounds int
+	block  cipher.Block
+	hash   tweakableHash
+}
+
+// NewCipher returns a new *adiantum.Cipher implementing Adiantum with
+// XChaCha/r (r = 8, 12 or 20) as stream cipher. Adiantum is specified
+// with r = 12. The key must be 256 bits long - otherwise a non-nil error
+// is returned. If the number of rounds is invalid, chacha.ErrRounds is
+// returned.
+func NewCipher(key []byte, rounds int) (*Cipher, error) {
+	if rounds != 20 && rounds != 12 && rounds != 8 {
+		return nil, chacha.ErrRounds
+	}
+	if len(key) != chacha.KeySize {
+		return nil, errKeySize
+	}
+
+	c := &Cipher{rounds: rounds}
+	copy(c.key[:], key)
+
+	var keys [32 + 16 + 16 + nhKeySize]byte
+	c.xorKeyStream(keys[:], keys[:], nil)
+	c.block, _ = aes.NewCipher(keys[:32])
+
+	h := new(nhPoly1305)
+	copy(h.keyT[:16], keys[32:48])
+	copy(h.keyM[:16], keys[48:64])
+	copy(h.keyNH[:], keys[64:])
+	c.hash = h
+
+	for i := range keys {
+		keys[i] = 0
+	}
+	return c, nil
+}
+
+// NewHPolyC returns a new *adiantum.Cipher implementing HPolyC with
+// XChaCha/r (r = 8, 12 or 20) as stream cipher. HPolyC is specified
+// with r = 12. The key must be 256 bits long - otherwise a non-nil error
+// is returned. If the number of rounds is invalid, chacha.ErrRounds is
+// returned.
+func NewHPolyC(key []byte, rounds int) (*Cipher, error) {
+	if rounds != 20 && rounds != 12 && rounds != 8 {
+		return nil, chacha.ErrRounds
+	}
+	if len(key) != chacha.KeySize {
+		return nil, errKeySize
+	}
+
+	c := &Cipher{rounds: rounds}
+	copy(c.key[:], key)
+
+	var keys [32 + 16]byte
+	c.xorKeyStream(keys[:], keys[:], nil)
+	c.block, _ = aes.NewCipher(keys[:32])
+
+	h := new(polyHash)
+	copy(h.key[:16], keys[32:])
+	c.hash = h
+
+	for i := range keys {
+		keys[i] = 0
+	}
+	return c, nil
+}
+
+// Encrypt encrypts src using the tweak and writes the result to dst.
+// Src and dst may be the same slice but otherwise should not overlap.
+// If len(src) < BlockSize or len(dst) < len(src) this function panics.
+func (c *Cipher) Encrypt(dst, src, tweak []byte) {
+	if len(src) < BlockSize {
+		panic("adiantum: src is too small")
+	}
+	if len(dst) < len(src) {
+		panic("adiantum: dst buffer is too small")
+	}
+	n := len(src) - BlockSize
+
+	var h, m [16]byte
+	c.hash.sum(&h, src[:n], tweak)
+	add(&m, src[n:], &h)
+	c.block.Encrypt(m[:], m[:])
+
+	c.xorKeyStream(dst[:n], src[:n], m[:])
+	c.hash.sum(&h, dst[:n], tweak)
+	sub(dst[n:len(src)], &m, &h)
+}
+
+// Decrypt decrypts src using the tweak and writes the result to dst.
+// Src and dst may be the same slice but otherwise should not overlap.
+// If len(src) < BlockSize or len(dst) < len(src) this function panics.
+func (c *Cipher) Decrypt(dst, src, tweak []byte) {
+	if len(src) < BlockSize {
+		panic("adiantum: src is too small")
+	}
+	if len(dst) < len(src) {
+		panic("adiantum: dst buffer is too small")
+	}
+	n := len(src) - BlockSize
+
+	var h, m [16]byte
+	c.hash.sum(&h, src[:n], tweak)
+	add(&m, src[n:], &h)
+
+	c.xorKeyStream(dst[:n], src[:n], m[:])
+	c.block.Decrypt(m[:], m[:])
+	c.hash.sum(&h, dst[:n], tweak)
+	sub(dst[n:len(src)], &m, &h)
+}
+
+// xorKeyStream crypts src using XChaCha/r with the nonce
+// nonce || 1 || 0...0 and writes the result to dst.
+func (c *Cipher) xorKeyStream(dst, src, nonce []byte) {
+	if len(src) == 0 {
+		return
+	}
+	var xNonce [chacha.XNonceSize]byte
+	xNonce[copy(xNonce[:], nonce)] = 1
+
+	stream, err := chacha.NewXCipher(xNonce[:], c.key[:], c.rounds)
+	if err != nil {
+		panic(err)
+	}
+	stream.XORKeyStream(dst, src)
+}
+
+// add computes out = x + y mod 2^128.
+func add(out *[16]byte, x []byte, y *[16]byte) {
+	lo := binary.LittleEndian.Uint64(x[0:]) + binary.LittleEndian.Uint64(y[0:])
+	hi := binary.LittleEndian.Uint64(x[8:]) + binary.LittleEndian.Uint64(y[8:])
+	if lo < binary.LittleEndian.Uint64(y[0:]) {
+		hi++
+	}
+	binary.LittleEndian.PutUint64(out[0:], lo)
+	binary.LittleEndian.PutUint64(out[8:], hi)
+}
+
+// sub computes out = x - y mod 2^128.
+func sub(out []byte, x, y *[16]byte) {
+	lo := binary.LittleEndian.Uint64(x[0:]) - binary.LittleEndian.Uint64(y[0:])
+	hi := binary.LittleEndian.Uint64(x[8:]) - binary.LittleEndian.Uint64(y[8:])
+	if binary.LittleEndian.Uint64(x[0:]) < binary.LittleEndian.Uint64(y[0:]) {
+		hi--
+	}
+	binary.LittleEndian.PutUint64(out[0:], lo)
+	binary.LittleEndian.PutUint64(out[8:], hi)
+}
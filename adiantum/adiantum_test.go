@@ -0,0 +1,289 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package adiantum
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/aead/chacha20/chacha"
+)
+
+func toHex(bits []byte) string {
+	return hex.EncodeToString(bits)
+}
+
+func fromHex(bits string) []byte {
+	b, err := hex.DecodeString(bits)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestAdiantumVectors(t *testing.T) { testVectors(t, NewCipher, adiantumVectors) }
+
+func TestHPolyCVectors(t *testing.T) { testVectors(t, NewHPolyC, hpolycVectors) }
+
+func testVectors(t *testing.T, newCipher func([]byte, int) (*Cipher, error), vectors []vector) {
+	for i, v := range vectors {
+		c, err := newCipher(v.key, v.rounds)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create cipher: %v", i, err)
+		}
+
+		ciphertext := make([]byte, len(v.plaintext))
+		c.Encrypt(ciphertext, v.plaintext, v.tweak)
+		if !bytes.Equal(ciphertext, v.ciphertext) {
+			t.Errorf("Test %d: ciphertext mismatch:\n \t got:  %s\n \t want: %s", i, toHex(ciphertext), toHex(v.ciphertext))
+		}
+
+		plaintext := make([]byte, len(v.ciphertext))
+		c.Decrypt(plaintext, v.ciphertext, v.tweak)
+		if !bytes.Equal(plaintext, v.plaintext) {
+			t.Errorf("Test %d: plaintext mismatch:\n \t got:  %s\n \t want: %s", i, toHex(plaintext), toHex(v.plaintext))
+		}
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	key := make([]byte, 32)
+	tweak := make([]byte, 32)
+	for _, newCipher := range []func([]byte, int) (*Cipher, error){NewCipher, NewHPolyC} {
+		c, err := newCipher(key, 12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for size := BlockSize; size < 2*nhMsgSize+64; size += 73 {
+			msg := make([]byte, size)
+			for i := range msg {
+				msg[i] = byte(i)
+			}
+			ref := make([]byte, size)
+			c.Encrypt(ref, msg, tweak)
+
+			c.Encrypt(msg, msg, tweak)
+			if !bytes.Equal(msg, ref) {
+				t.Fatalf("Size %d: in-place Encrypt mismatch:\n \t got:  %s\n \t want: %s", size, toHex(msg), toHex(ref))
+			}
+			c.Decrypt(msg, msg, tweak)
+			for i := range msg {
+				if msg[i] != byte(i) {
+					t.Fatalf("Size %d: in-place Decrypt produced a wrong plaintext", size)
+				}
+			}
+		}
+	}
+}
+
+func TestBadKeyLength(t *testing.T) {
+	if _, err := NewCipher(make([]byte, 16), 12); err == nil {
+		t.Error("NewCipher accepted a 128 bit key")
+	}
+	if _, err := NewHPolyC(make([]byte, 16), 12); err == nil {
+		t.Error("NewHPolyC accepted a 128 bit key")
+	}
+}
+
+func TestBadRounds(t *testing.T) {
+	if _, err := NewCipher(make([]byte, 32), 10); err != chacha.ErrRounds {
+		t.Errorf("NewCipher returned %v - want %v", err, chacha.ErrRounds)
+	}
+	if _, err := NewHPolyC(make([]byte, 32), 10); err != chacha.ErrRounds {
+		t.Errorf("NewHPolyC returned %v - want %v", err, chacha.ErrRounds)
+	}
+}
+
+func benchmarkEncrypt(b *testing.B, newCipher func([]byte, int) (*Cipher, error), size int) {
+	key := make([]byte, 32)
+	tweak := make([]byte, 32)
+	c, _ := newCipher(key, 12)
+	buf := make([]byte, size)
+
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Encrypt(buf, buf, tweak)
+	}
+}
+
+func BenchmarkAdiantum_512(b *testing.B) { benchmarkEncrypt(b, NewCipher, 512) }
+func BenchmarkAdiantum_4K(b *testing.B)  { benchmarkEncrypt(b, NewCipher, 4096) }
+func BenchmarkHPolyC_512(b *testing.B)   { benchmarkEncrypt(b, NewHPolyC, 512) }
+func BenchmarkHPolyC_4K(b *testing.B)    { benchmarkEncrypt(b, NewHPolyC, 4096) }
+
+type vector struct {
+	key, tweak, plaintext, ciphertext []byte
+	rounds                            int
+}
+
+var adiantumVectors = []vector{
+	{ // Adiantum-XChaCha12-AES256
+		fromHex("7fc7152ae1f5fda4176769aec92bba82a314e7cfadfd8540da7b7d24bdf17d07"),
+		nil,
+		fromHex("9be382c65ac19fad4659b80bacc857a0"),
+		fromHex("820ae44477dd9a186f80288b25070e85"),
+		12,
+	},
+	{ // Adiantum-XChaCha12-AES256
+		fromHex("fa60e3250b4e123a25073b4c3e1c7837db0a16a544c8c77171cedc3e82cbf3fa"),
+		fromHex("e1e64d4ca5c74440c7546ba3544eb81b7f"),
+		fromHex("6063deb6e2abae701abefd8e10c80b83d471e008d56c66cff229b9752e8da6"),
+		fromHex("a56c9b7608b51b213edd21fa6d67b483d646543d92fab95e1a74d95cabedbb"),
+		12,
+	},
+	{ // Adiantum-XChaCha12-AES256
+		fromHex("a52824341a3cd8f705918fee851f357f803dfc9b94f6fc9e190900a904314f11"),
+		fromHex("a1ba4995ff346db8cd875d5efdea85db8a7b5eb25d57dd62aca98c41429475b7"),
+		fromHex("69b4e88c37e86782f1ec5d04e5149113dff2871b69811d71709e9c3bde497011a0a3db0d544f6669d7db80a7709268ce81042cc6abaee56015e96fefaa8fa7a7" +
+			"638ff2f077f1a8eae1b71f9eab9e4b3f07875b6fcda8afb9fa700b52b8a8a79e075fa60eb39b791379c33e8d1c2c68c8511d3c7b7d79772a5665c5542328b003"),
+		fromHex("9e16abed4ba7425ac6fb4e76ffbe03a00fe3adbae4982b0e2148a0b865482748845454b29a947be64b29e9cf0591801a3af34196851d9f74515663fa7c288549" +
+			"f72ff9f21846f53380a33cceb25793f5aebda9f57b30c49366e0307716e4a031ba70bc6813f5b09ac1fc7efe55805c4874a6aaa3acdcc2f58dde34867860758d"),
+		12,
+	},
+	{ // Adiantum-XChaCha12-AES256
+		fromHex("ebe5113a72eb10be70cfe3eac274a448290f8f3fcf4c282a4e1e3cc3279f1613"),
+		fromHex("843ea27c0672b2ad887665b41a29271245b68d0e4b8704fcb5cd1c4de806f1cb"),
+		fromHex("8eb6079b7ce4a4a2416c241dc0774ed94aa42cb6e455027fc4ecabc25c634092382462db6582107f21a5393a3f387ead6c7bc93f898fa808bd31573c7a456730" +
+			"a9275834bee3a4c3ffc29f43f004ba1eb6f3c4ce097a2e427dad97c9779a3a786caf7c2a46b441861a20f25b1a60c9c4475d10a4d2156a194fd55137d506701a" +
+			"3e78f02eaab52abd83097ccb29acd79cbf80fd9dd4cf64caf8c9f1772ebb3926acd9bece247fbba282baeb5f65c5f1568a52024d45236debb0607bd86eb298d2" +
+			"af76f2339bf3bb95c050aac747f6b3f37716cb1495bf1d32450c75522ce8d731c087b0973030c55e50706eb04b4e381946ca386aca7dfe05c8807c146c24b542" +
+			"28044cff9820081090310378d8a1e6f952c2fc3ea768ceeb595debd8644ef88b2462cf173684c072604f3e47da723b0ece0ba99c51dca5b97173084e2231fd88" +
+			"29fc8d173a7ae5b90b9c6ddbcedbde81735a169d3c7288511016f3116e325f4c87ce882cd2aff5b7d822edc9ae687fc53062bec9e027a1b557743660b86b8cec" +
+			"14aded69c9d8a55b38075bf33e744890611723dd44bc9d120a3a63b2ab86b86785d6b25dde4ac1732a7c538ed67d0ee43babc53d327918b7d6504df08a37bbd3" +
+			"8dd808d77daa2452f790e3aad6497a47ec37ad748bc1b7fe4f701462228c63c21c4e38c363b7bf53bd1faca694c581fae0eb81e9d91d323c8512ca6165d166d8" +
+			"e20ec3a3ff0dd3eedfcc3e01f59b455c33b5b08d361adff8a381bedb3d4bf6c6df7fb089bd393250bbb2e35cbb4b1898086651e74dfbfc4e22426f61db7f2788" +
+			"293f02a9c68330cc8bd5647b7c7616beb68b26b88316f26bd1dc206b425aef7aa960b81ad30d4ecb756bc58043387fad9c56d9c4f10174f016538d69bef25d92" +
+			"3438c884f91afc2616cbae7d382167744c40aa6b97e0b02ff53ef6e224c822a4a888278644755b2934084ba1fe0c26e5ac26f6210cfbde14fed7beee4893d699" +
+			"569ccf22ada25341fd58a168dcc4ef20a1eecf2b43b657d8fe018025dfd235440d1515c3fc49bfd0bf2f958109a6b6d72103fe52b7a8324d751e4644bc2b6104" +
+			"1b1ceb39868fe949ce78a55e67c5e9ef43f8f135224361c127b509b2b8e15e26ccf36fb2b755309887fce7a8c89486a1d9a03c7416b32598bac6844a27a658fe" +
+			"e1680430c8db44524eb2a46ff763f2d663361704f806dbeb9917a51b6190a39f05ae3ee4dbc81c8e772788dfd3225ac59cd622f8c4d8929d16cc54253b6fdbc0" +
+			"78d8e3b30369d75df8080463619d76f9ad1dc4309f75896bfb62baaecb1b6ce57eea586baece9b484b80d45e7153a72473caf53ebb5ed31c33e3ec5ba0329d25" +
+			"0e0c28293951c570ec608f77fc067a3319d57a6e94eaa3eb13a42e09d881658303638bb5c989987369538eabf1d22f67bda6166ed08bc12593d2507c1fe111d0" +
+			"580d2f72e75edba2559ae00921ac61854b2095736326e3834b5b400314b04416bde00eb76656d730b3fd8ad3da6aa73d980911b70006245af74294a60eb16d48" +
+			"74b1a7e6920a159af5fa551a6cdd7108d0f78d0e7c674dc6e6de7888883c5e2346d225a4fba3263f2bfd9c20da72e1818fe6ae081d6715de86691dc61e6db75c" +
+			"dd43725a7da7d8d71e66c590f6517691b3e339817508fac50670691b2c2074e053b00c9ddaa95bdd1c386c9e3bc47a82939ebb75fb194a55657a3cdacb665c13" +
+			"1797e8bdae24d976fb8c73debdb41be0b92ce8e01d3fa82c1e815b77e7df6d067c9af02b5dfc86d5b1adbca873486167d6bac8e8e2b8ee4036223e61f6c816e4" +
+			"0e88ad715358e16c8f4f894b3e9c7fe9adc228c23a29f3eca92839bac286e106f38be3950c87b81b72358e8f6d18c81ca55d579d738abb9e210512d7e0211c16" +
+			"3a9585bcb0710b366c448def3bec3f8e24a9e3a76323ca096296790c810541f2072026e58e105403057bfe0ccc8c50e5ca334d487a03d5644909f25c5dfe2b30" +
+			"bf2914298b9b7c964707864d4e4df147d1102aa8d3158cf22ff43adfd0a7cb5aad99394adf60bef9914ef594efc55632338678a3d64c297ce8ac06b5f5015c9f" +
+			"02c8e8bf5c1a7f4d28a5b9daa95ee74bf43de91d28aa1a8a76c86c19613c9e29cdbeffe01cb867b5a446f8b98aa2f67cef23730ce9720a0d9b40d8fb0c9caba8"),
+		fromHex("cb78879cc713c130dd2c7db297ab066947878a122b5d86d72ee67a0d585de701780effc7c5d294d6dd6b381fa4e33de7c58ab5be65112be12b8e84e8e0007fdd" +
+			"1515abbd2294f7ce996ffd0e9b16ebeb24c7bbc6e16c57ba84ab16f257d6429d56925b4418d4a21b1ea9dc7a1688c44f6d779a2e82a9c3eea4ca051b0edc4896" +
+			"d050211f46c7c77053cd1e4e5f2d4bb286e53ae61dec7b9d8fd641c6bb004fe602470773506bcfb29e1c01c909ccc35227e663e05b55604d72d0da4beccb725d" +
+			"374af5b8d9e20810f3b9dc07c00210149fe68fc4c4e1397b47eaae7cdd27a84c6b0f4cf8ff164ecbec88330d15108266a73d2cb6bc2ee4ce4c2f4b460f6778a5" +
+			"ff6a7d0d5e6dabfb5999d81f30d433e87d11aee3bad03fa7a55e43daf30f3a5fbab047b20860f4ed35230ce94f81c4c5a835dc99523319d400018d5a10823978" +
+			"fc7224634a38c56ffeec2f260c3c1cf64d997a7759fe10a5a135bf2f15fa4e52e6d51c889075d5ccdb2ab1f0705489c7eb1d6e6145a35048cddb32ba7f6bafef" +
+			"50cb0d36f7293a100273ca8f3f5d8217919ad81515e3e14143ef85a6b0c73b0ff0a5aa6677705e70ce17846845392c25c6c15f7ee8fae43a47517b9d54849804" +
+			"5ff75f3c34e7a31deab76d05ab28e42cb17f08a85d07bffe3972448751c573e49a5fdd46bc4eb139e478b8bfdc5b889bc13fd9d0b35adfaa536a916d2a09f00b" +
+			"5ee8b2a0b473071dc83384e6dae6add6ad91014e1442342ce5f99921561f6c2b4ce3d59e04dc9a16d154e9c2f7c0d5062fa1382a558823f8b0db8732c94eb00c" +
+			"c5057858a12e757568dceadd0c33165ee7dcfd4274beae603c374b27f52c5f554a0b64fda201659c279f5e87d5958866098442ab00e258c39745f193e234373d" +
+			"fe938c17b9796506f758e51b3b4eda3617e356ec260f2efad1b92b3e7f1de34b67df435310baa3fb5d5ad8c4ab197e12aa83f1c0a1e0bf725fe86839ef1abeee" +
+			"6f477919edf2a14ae5fcb558ae6382cb160b94bb3e0249c43c33f1ec1b11719b5b80f16f881c0536a8d8ee44b518c31462ba98b9c02a7093b3d81169951d437b" +
+			"39c19105c4e31ec21e5de7debefdae994b8f831ef49bb02b666e62248de01b2259ebbd2a6b2e37179e1f66cb66b4fb2c36225d7356c1b027e0f01be4478bc6dc" +
+			"7c0c3d29cb3310fec3c31eff4c9b2786e2b0afb789ce6169e7003e92ea5f9ec1fa6b20e2412382eb07764c4c2a9633be89a9a8b99a7d271848237046f387a791" +
+			"58b874baedc6b2a14db6439ae1a241a535d3908ac74db7880be3749f84fcd973f2860cadeb5d70ac6507148e57f6dcb4c2027cd689e28a3e8e083c1237afe1a8" +
+			"04115cae5a2b60a0033c7aa23892bece09a25e0fc2b2b506c297979b092f04fe2ce7a3c442e9a340a552072c3b891aa528b19305980c2f3dc6f583ac241d289f" +
+			"32664d70b7e0abb875c5f3d27b263eec64e6f770e7f8108e67d2b3876940069a2f6a1afd620cee312ebe589777d109081f8d422934d5d8b51fd72118e3e72e4a" +
+			"42fcdb19e9eeb922ad5c07e9c807e5e995a20d3046e2655101a57485e2526e07c9f53309de7862a9302ad386e5462e60ff74b05fec76b7d15e4d61973c9c99c3" +
+			"41652147f9b106ec18f83fc738fa7b1462796a0b0cf52cb7abcf63496d1f46a8bc7d4253756bca38ac8be7a1a192196b0d75805b7d358670126be53ee585a0a4" +
+			"d6775e4d245784a9e5a4bf25fb36653b813961ec5e4a7e105819135c0f79eccfbb5f6921c3a75aff3bc7859b47bc3eadbf5460b65b3ffc5068837624b0c33f93" +
+			"0dce360a589dcce952bbd00b65e50f628216aad2ba5a4cd067b54e841c026ea3aa225496c8d99c581563f4981aa1d911642556b5038e29857588d1d2e4e62748" +
+			"139c2baafbd36e2ce6d4e48bd9f7011646f95c887a939e2da6eb012a72e47fb4780c5018d38e65a71bf9285d8970962fa1c29b34fc7c276393e6e3a49d17977e" +
+			"13799c4b2c23912c4fb11d4bb4616ee83235c3417a5060c83ed83f38fcc2a2e03a21258fc222ed0431b87269af6c6dab2516958792c7463f47056cada0a61df0" +
+			"662e011ac3bee4f651eca39581e1ccabc171650ae653fbb85369ad8bab8ba7cd8f150125b11f9c3b9b47ad3838896b1c8a33dd8a0623060b7f70be7ea180bc7a"),
+		12,
+	},
+	{ // Adiantum-XChaCha20-AES256
+		fromHex("7fc7152ae1f5fda4176769aec92bba82a314e7cfadfd8540da7b7d24bdf17d07"),
+		nil,
+		fromHex("9be382c65ac19fad4659b80bacc857a0"),
+		fromHex("8899bb928f2bf9264eafdee237d431aa"),
+		20,
+	},
+	{ // Adiantum-XChaCha20-AES256
+		fromHex("c3315bbe2696e05b88d5c34d578ded7c06770a4b8c99b3557ce039113660da83"),
+		fromHex("1a95746d43be910ddedd6f84b9c216f873"),
+		fromHex("896e72fdf286b35b5521453ce78a58f68b32ab82a1995533019d69a86e077483eb8046575517ec04938aea8fbdf79f0ec2359355649e4cd1e50d123c8ac7d3a3" +
+			"c213220fb5a6beeb546a2a12c894eed5e38fdf4a2452093f6141c5becd6c4e8a40692a21d6c9aae9109a531f91913bbb62df367066e0fa0fe33eaa3eacd940e4" +
+			"54240ce9c98d54af13db38d6c7cd3b6314da610e18c39d2a9e0b0d00e1b9a1d9e62a5d7cdbb348e1de2524df6af0336e4227b2927fe11f10b648aa0ac6248017" +
+			"b8583cc28881a1a0801c7eb0a0f9f2fc6836a8f5439684fd39c133e09d7b2571a94e79625c71148a20480e81b729817dc2d5e99c93be44077fa96cf497d5532c" +
+			"a08db0399578ac27006abc23c080ad0f0e92c30508227d9cce94b7a2895687410b696189a74338cdabaf31f54a348dd85750bd592f5404be72940a7fe0a234f7" +
+			"3596e188b5cac7c575f89bf54a4f648158f4c7f9b75124dcaa8e0df6bac2cc1d6333445cb84ab5f7ca3bf3f61fafa2ab40af3e9220c489177cda90ceae683047" +
+			"5bfcc36b587c09e7a2019306f9d968236fab4b4094dcb890f2c0e21f2e1e1024774867620e45e5265ae4fd2f20cf284092ba7dd927bc80226cc55d6d9440391e" +
+			"bacf2f3e518e11e73d3abd9bd1c680dc7a1a36dbaaddbb14343db67e8f93f16767c97ddbec691888cc911ed29297c49d0e0b3871a80d440d9d3c8fe0d0e4e1d3"),
+		fromHex("88e369a572099f5cfafec0e0f22e344133be172c7425c2efdae262fb7de9c1f2304ab25e42c83b98fc7f8399ba2620f6e1c350c9c43bca386febca6bf4f886b9" +
+			"70a1e4c8ec56a7ab869c941871d47bd76e59a62a26ee39ed9972ccb5ae8b1d6335a6a81be1e0aa847d2dbb72a71763fad60b443eb6c6ee257a341a9e77be5a07" +
+			"e139371b0446ee1a7a09d4799ed35f415477d56c078b26de18651228e81119d9b60888f614b4a818fd8225463df1a07f1282e6f4a642c1e5ad638ff599b06fed" +
+			"59a694c26eac2daba6340918fb3f7e5546591e3f5b30607e7992329ffd894156e40d08917d966255249fad5d0fbcd69a205565915c747176b576231245ccdc8c" +
+			"ab91db361e5c6f10b35f4c7b490df53710c70f2f5db86d0827834188c9cb52669a5eb18469f8062a89f282256a6d0d6715add68529ff4d46ea3e5d901dcffec1" +
+			"6b366ff1d98f48d0e09cd367aa42597bbf81b471b0b317ec4220806923247416599a79db92adbbbd576c6af0ecb1818ec9ef43689501ffc374f5fc03282e9ca8" +
+			"f4b4fd878760344cb169f07b1d8d0a2812ae4787518dd6d69510d8ec70a8ee812fcfcf99acd8681e71d4f0b20fd2e6e382e01099e1d7ff875eca9a2afafa2ac2" +
+			"ba732e3c442ea6ae05f6837262a8f5587f21f485e60c551714b4cde9d449af3271960561e9b94d288634653eeaf585d165f99ec74014738bac865939fac19e63"),
+		20,
+	},
+	{ // Adiantum-XChaCha8-AES256
+		fromHex("362b5797f85dcd995f1a5a441d920f27cc16d72b856399d3ba96a1dbd26068da"),
+		fromHex("ef5869b12c5e9a4724c1b169e112938f433d6d00db5ed8d9129afed9ff2daac4"),
+		fromHex("5ea8681985981223260accdb0a04b9df4db3487bb0e3c819435a4606942df2"),
+		fromHex("4cc93bd94f03589e2f349ce6387e510b182b0a1974997bbc4ecaca405869ff"),
+		8,
+	},
+}
+
+var hpolycVectors = []vector{
+	{ // HPolyC-XChaCha12-AES256
+		fromHex("7fc7152ae1f5fda4176769aec92bba82a314e7cfadfd8540da7b7d24bdf17d07"),
+		nil,
+		fromHex("9be382c65ac19fad4659b80bacc857a0"),
+		fromHex("820ae44477dd9a186f80288b25070e85"),
+		12,
+	},
+	{ // HPolyC-XChaCha12-AES256
+		fromHex("fa60e3250b4e123a25073b4c3e1c7837db0a16a544c8c77171cedc3e82cbf3fa"),
+		fromHex("e1e64d4ca5c74440c7546ba3544eb81b7f"),
+		fromHex("6063deb6e2abae701abefd8e10c80b83d471e008d56c66cff229b9752e8da6"),
+		fromHex("d4e4a09e91d2fbe43232baa2e08f8fede1723b4b37455ea4a1ea633bf9745a"),
+		12,
+	},
+	{ // HPolyC-XChaCha12-AES256
+		fromHex("a52824341a3cd8f705918fee851f357f803dfc9b94f6fc9e190900a904314f11"),
+		fromHex("a1ba4995ff346db8cd875d5efdea85db8a7b5eb25d57dd62aca98c41429475b7"),
+		fromHex("69b4e88c37e86782f1ec5d04e5149113dff2871b69811d71709e9c3bde497011a0a3db0d544f6669d7db80a7709268ce81042cc6abaee56015e96fefaa8fa7a7" +
+			"638ff2f077f1a8eae1b71f9eab9e4b3f07875b6fcda8afb9fa700b52b8a8a79e075fa60eb39b791379c33e8d1c2c68c8511d3c7b7d79772a5665c5542328b003"),
+		fromHex("42e5f779d038ebb0ab6e3c8f69c5ea416a2f553504d522703517fb2d22bc3fec90bc4e20a0108f505f001acd6255ebb5284fdfe6ef807c011432446a9bbf5d80" +
+			"a236e0407fbd5bc526e68627b64a6290b6713577b47484d03e60002635f11a9932b3d8f1e49801a4aada8bd36e5c6168446e746380f3a1d8d3f8037c63bbcdbd"),
+		12,
+	},
+	{ // HPolyC-XChaCha20-AES256
+		fromHex("c3315bbe2696e05b88d5c34d578ded7c06770a4b8c99b3557ce039113660da83"),
+		fromHex("1a95746d43be910ddedd6f84b9c216f873"),
+		fromHex("896e72fdf286b35b5521453ce78a58f68b32ab82a1995533019d69a86e077483eb8046575517ec04938aea8fbdf79f0ec2359355649e4cd1e50d123c8ac7d3a3" +
+			"c213220fb5a6beeb546a2a12c894eed5e38fdf4a2452093f6141c5becd6c4e8a40692a21d6c9aae9109a531f91913bbb62df367066e0fa0fe33eaa3eacd940e4" +
+			"54240ce9c98d54af13db38d6c7cd3b6314da610e18c39d2a9e0b0d00e1b9a1d9e62a5d7cdbb348e1de2524df6af0336e4227b2927fe11f10b648aa0ac6248017" +
+			"b8583cc28881a1a0801c7eb0a0f9f2fc6836a8f5439684fd39c133e09d7b2571a94e79625c71148a20480e81b729817dc2d5e99c93be44077fa96cf497d5532c" +
+			"a08db0399578ac27006abc23c080ad0f0e92c30508227d9cce94b7a2895687410b696189a74338cdabaf31f54a348dd85750bd592f5404be72940a7fe0a234f7" +
+			"3596e188b5cac7c575f89bf54a4f648158f4c7f9b75124dcaa8e0df6bac2cc1d6333445cb84ab5f7ca3bf3f61fafa2ab40af3e9220c489177cda90ceae683047" +
+			"5bfcc36b587c09e7a2019306f9d968236fab4b4094dcb890f2c0e21f2e1e1024774867620e45e5265ae4fd2f20cf284092ba7dd927bc80226cc55d6d9440391e" +
+			"bacf2f3e518e11e73d3abd9bd1c680dc7a1a36dbaaddbb14343db67e8f93f16767c97ddbec691888cc911ed29297c49d0e0b3871a80d440d9d3c8fe0d0e4e1d3"),
+		fromHex("d8a00d52489f2f52f92e9b391bd5156ba8a755f8ff27ccac9b294721b1916e0d11743e326614b8992549c13fd6d409f744fa4f6f651f95ef705336c80b02e7f0" +
+			"f6faee8e25a2c303b2bed7553c7a508b4da8913cb695989e7ab642f9d933f219bfbd8552ea938e11d28ab04018818085712181dce189328aec4ae5553ad72a3b" +
+			"a15a291a6acc0d5ef247cd1b703b61c6fec07ab65f9996f7beb60971e7956bca667965de5a46b6ee87d737c834058595f53acb9cecd85a9012b8ed303e1c83ee" +
+			"e86a2af997ce03a2c1d25cfb679f607697765bd5864f991eba2640cbfd4763c7460166948f8c24560cc2cfe15e3a1b70c0a08db6da63616cbce2a6cd7f187cfc" +
+			"2420f4ab1f756069ba0cecce9b427e4de0b16d7291ec3a6b41f1b7c9504608eb63efc0338028552de0513000ee6abe43209633fca0388998f0ec353732329b4a" +
+			"64c0f63db06f295f6981acb4a893e2fa933645230456acc0cbf3089f50f0c49b29a19cf9d5129dbeb5e33a9eb2acaed8d0e1ac6cf74306d4089a9cbedc712350" +
+			"8e3ed620a0a4003a11aba354ea6df8e094aed4a6405245368dde68a1fe19a7bfa74945eb4db72355700f8aff66da8fdc8fc729145c2d6d5c723a36faa975865d" +
+			"44d166fb6aa30f7b732e1e80c02b1b6e30c80e611f4693986496380829df335203e9053fcb91aa4e1f3ca76dd07d57bf7680621184bff93a887f056618ddd254"),
+		20,
+	},
+	{ // HPolyC-XChaCha8-AES256
+		fromHex("362b5797f85dcd995f1a5a441d920f27cc16d72b856399d3ba96a1dbd26068da"),
+		fromHex("ef5869b12c5e9a4724c1b169e112938f433d6d00db5ed8d9129afed9ff2daac4"),
+		fromHex("5ea8681985981223260accdb0a04b9df4db3487bb0e3c819435a4606942df2"),
+		fromHex("10a90c99c1ab109a8612e72864a5e2342bede68698c42feb4d912a6ae819bc"),
+		8,
+	},
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package adiantum
+
+import (
+	"encoding/binary"
+
+	"github.com/aead/chacha20/poly1305"
+)
+
+const (
+	nhMsgSize = 1024             // NH hashes the message in chunks of 1024 bytes
+	nhKeySize = nhMsgSize + 3*16 // Every NH pass uses a key shifted by 16 bytes
+)
+
+// nhPoly1305 is the tweakable hash of Adiantum. The tweak is hashed with
+// Poly1305 (key keyT) and the message is hashed with NH followed by
+// Poly1305 (key keyM). The result is the sum of both hashes mod 2^128.
+// The Poly1305 keys are clamped by poly1305 and their s-part is zero.
+type nhPoly1305 struct {
+	keyT, keyM [32]byte
+	keyNH      [nhKeySize]byte
+}
+
+func (h *nhPoly1305) sum(out *[16]byte, msg, tweak []byte) {
+	var hashT, hashM [poly1305.TagSize]byte
+
+	var length [16]byte
+	binary.LittleEndian.PutUint64(length[:], 8*uint64(len(msg)))
+	mac := poly1305.New(&(h.keyT))
+	mac.Write(length[:])
+	mac.Write(tweak)
+	mac.Sum(hashT[:0])
+
+	var nh [32]byte
+	mac = poly1305.New(&(h.keyM))
+	for len(msg) >= nhMsgSize {
+		nhSum(&nh, msg[:nhMsgSize], &(h.keyNH))
+		mac.Write(nh[:])
+		msg = msg[nhMsgSize:]
+	}
+	if len(msg) > 0 {
+		var block [nhMsgSize]byte
+		n := copy(block[:], msg)
+		n = (n + 15) &^ 15 // pad the last chunk to a multiple of 16 bytes
+		nhSum(&nh, block[:n], &(h.keyNH))
+		mac.Write(nh[:])
+	}
+	mac.Sum(hashM[:0])
+
+	add(out, hashT[:], &hashM)
+}
+
+// polyHash is the tweakable hash of HPolyC. It computes the Poly1305
+// hash of the tweak length, the tweak - padded to a multiple of 16 bytes -
+// and the message. The s-part of the Poly1305 key is zero.
+type polyHash struct {
+	key [32]byte
+}
+
+func (h *polyHash) sum(out *[16]byte, msg, tweak []byte) {
+	var pad [16]byte
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], 8*uint32(len(tweak)))
+
+	mac := poly1305.New(&(h.key))
+	mac.Write(length[:])
+	mac.Write(tweak)
+	if n := (len(length) + len(tweak)) % 16; n > 0 {
+		mac.Write(pad[n:])
+	}
+	mac.Write(msg)
+	mac.Sum(out[:0])
+}
+
+// nhSum computes the NH hash of msg using 4 passes. The length of
+// msg must be a multiple of 16 and not greater than nhMsgSize.
+func nhSum(out *[32]byte, msg []byte, key *[nhKeySize]byte) {
+	var sums [4]uint64
+	for i := 0; i < len(msg); i += 16 {
+		m0 := binary.LittleEndian.Uint32(msg[i:])
+		m1 := binary.LittleEndian.Uint32(msg[i+4:])
+		m2 := binary.LittleEndian.Uint32(msg[i+8:])
+		m3 := binary.LittleEndian.Uint32(msg[i+12:])
+
+		for j := range sums {
+			k := key[i+16*j:]
+			k0 := binary.LittleEndian.Uint32(k[0:])
+			k1 := binary.LittleEndian.Uint32(k[4:])
+			k2 := binary.LittleEndian.Uint32(k[8:])
+			k3 := binary.LittleEndian.Uint32(k[12:])
+
+			sums[j] += uint64(m0+k0) * uint64(m2+k2)
+			sums[j] += uint64(m1+k1) * uint64(m3+k3)
+		}
+	}
+	for i, v := range sums {
+		binary.LittleEndian.PutUint64(out[8*i:], v)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha20
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/aead/chacha20/chacha"
+	"github.com/aead/chacha20/poly1305"
+)
+
+// TagSize is the size of the Poly1305 authentication tag in bytes.
+const TagSize = 16
+
+var (
+	errAuthFailed = errors.New("chacha20: message authentication failed")
+	errKeySize    = errors.New("chacha20: bad key length")
+)
+
+// NewAEAD returns a cipher.AEAD implementing the ChaCha20-Poly1305
+// construction specified in RFC 8439. The key must be 256 bits long
+// and the nonce passed to Seal and Open must be 96 bits long.
+// The key-nonce combination must be unique for all time.
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != chacha.KeySize {
+		return nil, errKeySize
+	}
+	c := new(aead)
+	copy(c.key[:], key)
+	return c, nil
+}
+
+// NewXAEAD returns a cipher.AEAD implementing the XChaCha20-Poly1305
+// construction specified in draft-irtf-cfrg-xchacha. The key must be
+// 256 bits long and the nonce passed to Seal and Open must be 192 bits
+// long. The large nonce makes it safe to choose nonces at random.
+// XChaCha20-Poly1305 is compatible to libsodium's
+// crypto_aead_xchacha20poly1305_ietf.
+func NewXAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != chacha.KeySize {
+		return nil, errKeySize
+	}
+	c := new(xaead)
+	copy(c.key[:], key)
+	return c, nil
+}
+
+type aead struct {
+	key [32]byte
+}
+
+func (c *aead) NonceSize() int { return chacha.INonceSize }
+
+func (c *aead) Overhead() int { return TagSize }
+
+func (c *aead) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != chacha.INonceSize {
+		panic("chacha20: bad nonce length passed to Seal")
+	}
+	if uint64(len(plaintext)) > (1<<38)-64 {
+		panic("chacha20: plaintext is too large")
+	}
+	return seal(dst, nonce, plaintext, additionalData, &(c.key))
+}
+
+func (c *aead) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != chacha.INonceSize {
+		panic("chacha20: bad nonce length passed to Open")
+	}
+	if len(ciphertext) < TagSize {
+		return nil, errAuthFailed
+	}
+	if uint64(len(ciphertext)) > (1<<38)-48 {
+		panic("chacha20: ciphertext is too large")
+	}
+	return open(dst, nonce, ciphertext, additionalData, &(c.key))
+}
+
+type xaead struct {
+	key [32]byte
+}
+
+func (c *xaead) NonceSize() int { return chacha.XNonceSize }
+
+func (c *xaead) Overhead() int { return TagSize }
+
+func (c *xaead) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != chacha.XNonceSize {
+		panic("chacha20: bad nonce length passed to Seal")
+	}
+	if uint64(len(plaintext)) > (1<<38)-64 {
+		panic("chacha20: plaintext is too large")
+	}
+
+	var subKey [32]byte
+	var iNonce [chacha.INonceSize]byte
+	deriveSubKey(&subKey, &iNonce, nonce, &(c.key))
+	return seal(dst, iNonce[:], plaintext, additionalData, &subKey)
+}
+
+func (c *xaead) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != chacha.XNonceSize {
+		panic("chacha20: bad nonce length passed to Open")
+	}
+	if len(ciphertext) < TagSize {
+		return nil, errAuthFailed
+	}
+	if uint64(len(ciphertext)) > (1<<38)-48 {
+		panic("chacha20: ciphertext is too large")
+	}
+
+	var subKey [32]byte
+	var iNonce [chacha.INonceSize]byte
+	deriveSubKey(&subKey, &iNonce, nonce, &(c.key))
+	return open(dst, iNonce[:], ciphertext, additionalData, &subKey)
+}
+
+// deriveSubKey computes the XChaCha20 sub-key from the first 128 bits of
+// the nonce using HChaCha20. The remaining 64 bits of the nonce are prefixed
+// with 4 zero bytes to form the 96 bit nonce of the IETF construction.
+func deriveSubKey(subKey *[32]byte, iNonce *[chacha.INonceSize]byte, nonce []byte, key *[32]byte) {
+	var hNonce [16]byte
+	copy(hNonce[:], nonce[:16])
+	chacha.HChaCha20(subKey, &hNonce, key)
+	copy(iNonce[4:], nonce[16:])
+}
+
+// seal encrypts and authenticates the plaintext using ChaCha20 with
+// a 96 bit nonce and Poly1305. The Poly1305 key is taken from the
+// keystream block 0 - the plaintext is encrypted starting at block 1.
+func seal(dst, nonce, plaintext, additionalData []byte, key *[32]byte) []byte {
+	ret, out := sliceForAppend(dst, len(plaintext)+TagSize)
+	ciphertext, tag := out[:len(plaintext)], out[len(plaintext):]
+
+	var polyKey [32]byte
+	c, err := chacha.NewCipher(nonce, key[:], 20)
+	if err != nil {
+		panic(err)
+	}
+	c.KeyStream(polyKey[:])
+	c.SetCounter(1)
+	c.XORKeyStream(ciphertext, plaintext)
+
+	authenticate(tag, ciphertext, additionalData, &polyKey)
+	return ret
+}
+
+// open verifies and decrypts the ciphertext produced by seal.
+// The plaintext is only decrypted if the authentication tag is valid.
+func open(dst, nonce, ciphertext, additionalData []byte, key *[32]byte) ([]byte, error) {
+	tag := ciphertext[len(ciphertext)-TagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-TagSize]
+
+	var polyKey [32]byte
+	c, err := chacha.NewCipher(nonce, key[:], 20)
+	if err != nil {
+		panic(err)
+	}
+	c.KeyStream(polyKey[:])
+
+	var sum [TagSize]byte
+	authenticate(sum[:], ciphertext, additionalData, &polyKey)
+	if subtle.ConstantTimeCompare(sum[:], tag) != 1 {
+		return nil, errAuthFailed
+	}
+
+	ret, plaintext := sliceForAppend(dst, len(ciphertext))
+	c.SetCounter(1)
+	c.XORKeyStream(plaintext, ciphertext)
+	return ret, nil
+}
+
+// authenticate computes the Poly1305 tag over the padded additional data,
+// the padded ciphertext and the lengths of both as specified in RFC 8439.
+func authenticate(out, ciphertext, additionalData []byte, key *[32]byte) {
+	var pad [16]byte
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[0:], uint64(len(additionalData)))
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(ciphertext)))
+
+	mac := poly1305.New(key)
+	mac.Write(additionalData)
+	if n := len(additionalData) % 16; n > 0 {
+		mac.Write(pad[n:])
+	}
+	mac.Write(ciphertext)
+	if n := len(ciphertext) % 16; n > 0 {
+		mac.Write(pad[n:])
+	}
+	mac.Write(lengths[:])
+	mac.Sum(out[:0])
+}
+
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes and a
+// second slice that aliases into it and contains only the extra bytes. If the
+// original slice has sufficient capacity then no allocation is performed.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha20
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"testing"
+)
+
+func TestAEADVectors(t *testing.T) { testAEADVectors(t, NewAEAD, aeadVectors) }
+
+func TestXAEADVectors(t *testing.T) { testAEADVectors(t, NewXAEAD, xaeadVectors) }
+
+func testAEADVectors(t *testing.T, newAEAD func([]byte) (cipher.AEAD, error), vectors []aeadVector) {
+	for i, v := range vectors {
+		c, err := newAEAD(v.key)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create AEAD: %v", i, err)
+		}
+
+		ciphertext := c.Seal(nil, v.nonce, v.plaintext, v.additionalData)
+		if !bytes.Equal(ciphertext, v.ciphertext) {
+			t.Errorf("Test %d: ciphertext mismatch:\n \t got:  %s\n \t want: %s", i, toHex(ciphertext), toHex(v.ciphertext))
+		}
+
+		plaintext, err := c.Open(nil, v.nonce, ciphertext, v.additionalData)
+		if err != nil {
+			t.Errorf("Test %d: Open failed: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, v.plaintext) {
+			t.Errorf("Test %d: plaintext mismatch:\n \t got:  %s\n \t want: %s", i, toHex(plaintext), toHex(v.plaintext))
+		}
+
+		ciphertext[0] ^= 1
+		if _, err = c.Open(nil, v.nonce, ciphertext, v.additionalData); err == nil {
+			t.Errorf("Test %d: Open accepted a modified ciphertext", i)
+		}
+	}
+}
+
+func TestAEADInPlace(t *testing.T) {
+	var key [32]byte
+	nonce := make([]byte, 12)
+	c, err := NewAEAD(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for size := 0; size < 1024; size += 67 {
+		msg := make([]byte, size, size+TagSize)
+		for i := range msg {
+			msg[i] = byte(i)
+		}
+		ref := c.Seal(nil, nonce, msg, nil)
+
+		ciphertext := c.Seal(msg[:0], nonce, msg, nil)
+		if !bytes.Equal(ciphertext, ref) {
+			t.Fatalf("Size %d: in-place Seal mismatch:\n \t got:  %s\n \t want: %s", size, toHex(ciphertext), toHex(ref))
+		}
+		plaintext, err := c.Open(ciphertext[:0], nonce, ciphertext, nil)
+		if err != nil {
+			t.Fatalf("Size %d: in-place Open failed: %v", size, err)
+		}
+		for i := range plaintext {
+			if plaintext[i] != byte(i) {
+				t.Fatalf("Size %d: in-place Open produced a wrong plaintext", size)
+			}
+		}
+	}
+}
+
+func benchmarkSeal(b *testing.B, newAEAD func([]byte) (cipher.AEAD, error), size int) {
+	var key [32]byte
+	c, _ := newAEAD(key[:])
+	nonce := make([]byte, c.NonceSize())
+	buf := make([]byte, size, size+TagSize)
+
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Seal(buf[:0], nonce, buf, nil)
+	}
+}
+
+func BenchmarkSeal_64(b *testing.B)  { benchmarkSeal(b, NewAEAD, 64) }
+func BenchmarkSeal_1K(b *testing.B)  { benchmarkSeal(b, NewAEAD, 1024) }
+func BenchmarkXSeal_64(b *testing.B) { benchmarkSeal(b, NewXAEAD, 64) }
+func BenchmarkXSeal_1K(b *testing.B) { benchmarkSeal(b, NewXAEAD, 1024) }
+
+type aeadVector struct {
+	key, nonce, plaintext, additionalData, ciphertext []byte
+}
+
+var aeadVectors = []aeadVector{
+	{ // RFC 8439 - 2.8.2
+		fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f"),
+		fromHex("070000004041424344454647"),
+		fromHex("4c616469657320616e642047656e746c656d656e206f662074686520636c617373206f66202739393a204966204920636f756c64206f6666657220796f75" +
+			"206f6e6c79206f6e652074697020666f7220746865206675747572652c2073756e73637265656e20776f756c642062652069742e"),
+		fromHex("50515253c0c1c2c3c4c5c6c7"),
+		fromHex("d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d63dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b36" +
+			"92ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc3ff4def08e4b7a9de576d26586cec64b6116" +
+			"1ae10b594f09e26a7e902ecbd0600691"),
+	},
+	{
+		fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f"),
+		fromHex("000000000000000000000000"),
+		nil,
+		nil,
+		fromHex("3ae5d3f2a376d317eaea5aef0215ba54"),
+	},
+}
+
+var xaeadVectors = []aeadVector{
+	{ // draft-irtf-cfrg-xchacha - A.3.1
+		fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f"),
+		fromHex("404142434445464748494a4b4c4d4e4f5051525354555657"),
+		fromHex("4c616469657320616e642047656e746c656d656e206f662074686520636c617373206f66202739393a204966204920636f756c64206f6666657220796f75206f" +
+			"6e6c79206f6e652074697020666f7220746865206675747572652c2073756e73637265656e20776f756c642062652069742e"),
+		fromHex("50515253c0c1c2c3c4c5c6c7"),
+		fromHex("bd6d179d3e83d43b9576579493c0e939572a1700252bfaccbed2902c21396cbb731c7f1b0b4aa6440bf3a82f4eda7e39ae64c6708c54c216cb96b72e1213b452" +
+			"2f8c9ba40db5d945b11b69b982c1bb9e3f3fac2bc369488f76b2383565d3fff921f9664c97637da9768812f615c68b13b52ec0875924c1c7987947deafd8780a" +
+			"cf49"),
+	},
+	{ // libsodium - crypto_aead_xchacha20poly1305_ietf_encrypt
+		fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f"),
+		fromHex("404142434445464748494a4b4c4d4e4f5051525354555657"),
+		fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f" +
+			"404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f" +
+			"808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf" +
+			"c0c1c2c3c4c5c6c7c8c9cacbcccdcecfd0d1d2d3d4d5d6d7d8d9dadbdcdddedfe0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3f4f5f6f7f8f9fafbfcfdfe00" +
+			"0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f40" +
+			"4142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f606162636465666768696a6b6c6d6e6f707172737475767778797a7b7c7d7e7f80" +
+			"8182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebfc0" +
+			"c1c2c3c4c5c6c7c8c9cacbcccdcecfd0d1d2d3d4d5d6d7d8d9dadbdcdddedfe0e1e2e3e4e5e6e7e8e9eaebecedeeeff0f1f2f3f4f5f6f7f8f9fafbfcfdfe78"),
+		fromHex("6164646974696f6e616c2064617461"),
+		fromHex("f10d71f75ff5f25df31b7dd8faa3935a2256607d11518afbd2a3ef175e4813d7201d325e0f48b95a19facb6242be7075f1209827980e924796ddad6c415baa02" +
+			"01a1a0c7269efa228d3b53d2a8e387f11b069b58f1496aad5c8e4e4e4afbcfd522ea614a9d266ca16b8d1cbd1bcec515b171b840c27a0431e2e2c5c857244be8" +
+			"c6cbaaaef1fa575413b05a89d9c20c21cd0b5b74c1bd548a6045355deb4b4f4645c46bc8e3d45349e005d04784133597fe889888a84772f666d26d202206cb15" +
+			"c478680d7d54c37cca767b32cc622d7c7714c4747b38580553496a16921c21844d89263f17394a4082a253897726a71dbb7262e1cf97c28c7d0f77f5d2e9fd4f" +
+			"8101441d75de93d3a42b6e826d2812e2ec77675e73dac8b9c72ebe11d8bdebef5c1c3461fbb3231ec662482abccf801589f25ac47c4665c21dcc56626d40b808" +
+			"073b1a1a47924d76351a2f335d9bcdc2ba83fc6dfdb24f385ce7ef577353b9318f6b93e89dd8fa48ec7ef223580dd8a761dd7b199f41c1022fc83a8bd93fc876" +
+			"6c75d3376759d2f417b1069c01c684315396a618b2a99402b8f5d5a5dc0138e4c7e3aa3c5384ebb8a1ac530262a8ebbf5e03f7c7c4b49f4bf817937bf18fd43a" +
+			"44a08e0c89a07196783c9963c137a49c98f11b79185bf60844a09e3d983a497fab972e1a7302c5bf84fd9bfffc2fc257d62cdf4aa9e01213b18524030ea7ed04" +
+			"7e5b1970907556ab2aaee76c2169bd"),
+	},
+}
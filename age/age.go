@@ -0,0 +1,214 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// Package age implements the age (v1) file encryption format.
+//
+// An age file consists of a header and the payload. The header contains
+// one stanza per recipient - each stanza contains the random 128 bit file
+// key encrypted for one recipient - and a MAC of the header keyed by the
+// file key. The payload is encrypted with ChaCha20-Poly1305 using the
+// STREAM construction of the stream package with 64 KiB chunks. The key
+// of the payload is derived from the file key and a random nonce using
+// HKDF-SHA256.
+//
+// The package supports X25519 and scrypt (passphrase) recipients and is
+// compatible to the age CLI. Other recipient types can be implemented using
+// the Recipient and Identity interfaces. The ASCII armor is not supported.
+//
+// The format is specified in https://age-encryption.org/v1.
+package age // import "github.com/aead/chacha20/age"
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"github.com/aead/chacha20"
+	"github.com/aead/chacha20/chacha"
+	"github.com/aead/chacha20/stream"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	fileKeySize = 16
+	nonceSize   = 16
+)
+
+var (
+	// ErrIncorrectIdentity is returned by an Identity if none
+	// of the stanzas can be decrypted by the identity.
+	ErrIncorrectIdentity = errors.New("age: incorrect identity for recipient stanza")
+
+	errNoRecipients = errors.New("age: no recipients specified")
+	errNoIdentities = errors.New("age: no identities specified")
+	errHeaderMAC    = errors.New("age: bad header MAC")
+	errFileKeySize  = errors.New("age: bad file key length")
+	errNonce        = errors.New("age: failed to read the payload nonce")
+)
+
+// A Stanza is a section of the age header. It contains
+// the file key encrypted for one recipient.
+type Stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// A Recipient encrypts the file key. The file key is
+// decrypted by the corresponding Identity.
+type Recipient interface {
+	Wrap(fileKey []byte) ([]*Stanza, error)
+}
+
+// An Identity decrypts the file key from the stanzas of a header.
+// If none of the stanzas can be decrypted by the identity, Unwrap
+// must return ErrIncorrectIdentity.
+type Identity interface {
+	Unwrap(stanzas []*Stanza) (fileKey []byte, err error)
+}
+
+// Encrypt writes the header for the given recipients to dst and returns
+// an io.WriteCloser encrypting the payload. The caller must call Close
+// to write the final chunk of the payload.
+func Encrypt(dst io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errNoRecipients
+	}
+	fileKey := make([]byte, fileKeySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, err
+	}
+
+	h := new(header)
+	for _, r := range recipients {
+		stanzas, err := r.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		h.stanzas = append(h.stanzas, stanzas...)
+	}
+	for _, s := range h.stanzas {
+		if s.Type == "scrypt" && len(h.stanzas) != 1 {
+			return nil, errScryptNotAlone
+		}
+	}
+	h.mac = headerMAC(fileKey, h)
+	if err := h.marshal(dst); err != nil {
+		return nil, err
+	}
+	return NewPayloadWriter(dst, fileKey)
+}
+
+// Decrypt reads the header from src, decrypts the file key using the
+// first matching identity and verifies the header MAC. It returns an
+// io.Reader decrypting the payload. The io.Reader returns a non-nil
+// error if the payload has been modified or truncated.
+func Decrypt(src io.Reader, identities ...Identity) (io.Reader, error) {
+	if len(identities) == 0 {
+		return nil, errNoIdentities
+	}
+	h, payload, err := parseHeader(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileKey []byte
+	for _, id := range identities {
+		fileKey, err = id.Unwrap(h.stanzas)
+		if err == ErrIncorrectIdentity {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if fileKey == nil {
+		return nil, ErrIncorrectIdentity
+	}
+	if !hmac.Equal(headerMAC(fileKey, h), h.mac) {
+		return nil, errHeaderMAC
+	}
+	return NewPayloadReader(payload, fileKey)
+}
+
+// NewPayloadWriter writes the random nonce to dst and returns an
+// io.WriteCloser encrypting the payload with the key derived from
+// the 128 bit fileKey and the nonce. The caller must call Close
+// to write the final chunk of the payload.
+func NewPayloadWriter(dst io.Writer, fileKey []byte) (io.WriteCloser, error) {
+	if len(fileKey) != fileKeySize {
+		return nil, errFileKeySize
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(nonce); err != nil {
+		return nil, err
+	}
+	return stream.NewWriter(dst, payloadKey(fileKey, nonce), stream.DefaultChunkSize)
+}
+
+// NewPayloadReader reads the nonce from src and returns an io.Reader
+// decrypting the payload with the key derived from the 128 bit fileKey
+// and the nonce.
+func NewPayloadReader(src io.Reader, fileKey []byte) (io.Reader, error) {
+	if len(fileKey) != fileKeySize {
+		return nil, errFileKeySize
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(src, nonce); err != nil {
+		return nil, errNonce
+	}
+	return stream.NewReader(src, payloadKey(fileKey, nonce), stream.DefaultChunkSize)
+}
+
+// headerMAC computes HMAC-SHA256 of the header (without the MAC)
+// keyed by HKDF-SHA256(fileKey, "", "header").
+func headerMAC(fileKey []byte, h *header) []byte {
+	var buf bytes.Buffer
+	h.marshalWithoutMAC(&buf)
+	mac := hmac.New(sha256.New, hkdfKey(fileKey, nil, "header"))
+	mac.Write(buf.Bytes())
+	return mac.Sum(nil)
+}
+
+// payloadKey returns HKDF-SHA256(fileKey, nonce, "payload").
+func payloadKey(fileKey, nonce []byte) []byte { return hkdfKey(fileKey, nonce, "payload") }
+
+func hkdfKey(secret, salt []byte, info string) []byte {
+	key := make([]byte, chacha.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// aeadEncrypt encrypts the file key with ChaCha20-Poly1305 and a zero
+// nonce. The key must be used only once.
+func aeadEncrypt(key, fileKey []byte) ([]byte, error) {
+	aead, err := chacha20.NewAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil), nil
+}
+
+// aeadDecrypt decrypts the file key encrypted by aeadEncrypt. It returns
+// errFileKeySize if the ciphertext doesn't contain a 128 bit key - so a
+// ciphertext can't be crafted to be valid under multiple keys.
+func aeadDecrypt(key, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20.NewAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) != fileKeySize+aead.Overhead() {
+		return nil, errFileKeySize
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, nil)
+}
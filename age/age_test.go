@@ -0,0 +1,201 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The files in testdata are the age test vectors of the CCTV project
+// (https://github.com/C2SP/CCTV/tree/main/age) - except the armor and
+// the hybrid (post-quantum) vectors.
+func TestVectors(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No test vectors found")
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) { testVector(t, data) })
+	}
+}
+
+func testVector(t *testing.T, data []byte) {
+	var (
+		expect, payloadHash string
+		compressed          bool
+		identities          []Identity
+	)
+	r := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal("Test vector doesn't contain a file")
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		kv := strings.SplitN(line, ": ", 2)
+		if len(kv) != 2 {
+			t.Fatalf("Invalid test vector line: %q", line)
+		}
+		switch key, value := kv[0], kv[1]; key {
+		case "expect":
+			expect = value
+		case "payload":
+			payloadHash = value
+		case "compressed":
+			compressed = value == "zlib"
+		case "identity":
+			id, err := ParseX25519Identity(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			identities = append(identities, id)
+		case "passphrase":
+			id, err := NewScryptIdentity(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			identities = append(identities, id)
+		}
+	}
+
+	var file io.Reader = r
+	if compressed {
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file = zr
+	}
+
+	payload, err := Decrypt(file, identities...)
+	switch expect {
+	case "header failure":
+		if err == nil || err == ErrIncorrectIdentity || err == errHeaderMAC {
+			t.Fatalf("Decrypt returned %v - want a header failure", err)
+		}
+		return
+	case "no match":
+		if err != ErrIncorrectIdentity {
+			t.Fatalf("Decrypt returned %v - want %v", err, ErrIncorrectIdentity)
+		}
+		return
+	case "HMAC failure":
+		if err != errHeaderMAC {
+			t.Fatalf("Decrypt returned %v - want %v", err, errHeaderMAC)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, payload)
+	switch expect {
+	case "success":
+		if err != nil {
+			t.Fatalf("Decrypting the payload failed: %v", err)
+		}
+	case "payload failure":
+		if err == nil {
+			t.Fatal("Decrypting a modified payload succeeded")
+		}
+	default:
+		t.Fatalf("Unknown expectation: %q", expect)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != payloadHash {
+		t.Fatalf("Payload hash mismatch: got %s - want %s", sum, payloadHash)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	id0, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := ParseX25519Recipient(id1.Recipient().String())
+	if err != nil {
+		t.Fatalf("Failed to parse recipient: %v", err)
+	}
+	identity, err := ParseX25519Identity(id1.String())
+	if err != nil {
+		t.Fatalf("Failed to parse identity: %v", err)
+	}
+	scryptRecipient, err := NewScryptRecipient("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scryptRecipient.SetWorkFactor(10)
+	scryptIdentity, err := NewScryptIdentity("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, size := range []int{0, 1, 64 * 1024, 64*1024 + 1, 3 * 64 * 1024} {
+		plaintext := make([]byte, size)
+		for j := range plaintext {
+			plaintext[j] = byte(j)
+		}
+		for j, r := range [][]Recipient{{id0.Recipient(), recipient}, {scryptRecipient}} {
+			var file bytes.Buffer
+			w, err := Encrypt(&file, r...)
+			if err != nil {
+				t.Fatalf("Test %d-%d: Encrypt failed: %v", i, j, err)
+			}
+			if _, err = w.Write(plaintext); err != nil {
+				t.Fatalf("Test %d-%d: Write failed: %v", i, j, err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatalf("Test %d-%d: Close failed: %v", i, j, err)
+			}
+
+			id := Identity(identity)
+			if j == 1 {
+				id = scryptIdentity
+			}
+			payload, err := Decrypt(bytes.NewReader(file.Bytes()), id)
+			if err != nil {
+				t.Fatalf("Test %d-%d: Decrypt failed: %v", i, j, err)
+			}
+			out, err := ioutil.ReadAll(payload)
+			if err != nil {
+				t.Fatalf("Test %d-%d: Decrypting the payload failed: %v", i, j, err)
+			}
+			if !bytes.Equal(out, plaintext) {
+				t.Fatalf("Test %d-%d: plaintext mismatch", i, j)
+			}
+		}
+	}
+
+	if _, err := Encrypt(ioutil.Discard, scryptRecipient, recipient); err != errScryptNotAlone {
+		t.Errorf("Encrypt returned %v - want %v", err, errScryptNotAlone)
+	}
+	if _, err := Encrypt(ioutil.Discard); err != errNoRecipients {
+		t.Errorf("Encrypt returned %v - want %v", err, errNoRecipients)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package age
+
+import (
+	"errors"
+	"strings"
+)
+
+// The Bech32 encoding (BIP 173) of age recipients and identities.
+// In contrast to BIP 173 the length of the string is not limited.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var errBech32 = errors.New("age: invalid bech32 string")
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(hrp string, data []byte) uint32 {
+	chk := uint32(1)
+	step := func(v byte) {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range bech32Generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	for i := 0; i < len(hrp); i++ {
+		step(hrp[i] >> 5)
+	}
+	step(0)
+	for i := 0; i < len(hrp); i++ {
+		step(hrp[i] & 31)
+	}
+	for _, v := range data {
+		step(v)
+	}
+	return chk
+}
+
+// convertBits regroups the bits of data from groups of fromBits
+// to groups of toBits. If pad is false, the remaining bits must
+// be zero and less than fromBits.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var out []byte
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, errBech32
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errBech32
+	}
+	return out, nil
+}
+
+// bech32Encode returns the lower-case Bech32 encoding of data
+// with the human-readable part hrp.
+func bech32Encode(hrp string, data []byte) (string, error) {
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	hrp = strings.ToLower(hrp)
+	chk := bech32Polymod(hrp, append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+
+	s := make([]byte, 0, len(hrp)+1+len(values)+6)
+	s = append(s, hrp...)
+	s = append(s, '1')
+	for _, v := range values {
+		s = append(s, bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		s = append(s, bech32Charset[(chk>>uint(5*(5-i)))&31])
+	}
+	return string(s), nil
+}
+
+// bech32Decode decodes the Bech32 string s and returns the
+// lower-case human-readable part and the data.
+func bech32Decode(s string) (hrp string, data []byte, err error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errBech32 // mixed case
+	}
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errBech32
+	}
+	hrp = s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, errBech32
+		}
+	}
+	values := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, errBech32
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(hrp, values) != 1 {
+		return "", nil, errBech32
+	}
+	data, err = convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package age
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// The age header:
+//
+//	age-encryption.org/v1
+//	-> type arg1 arg2 ...
+//	base64 body - 64 columns per line, the last line is shorter (possibly empty)
+//	-> ...
+//	--- base64 MAC
+//
+// All base64 values are unpadded and canonical. The header MAC covers
+// the header up to and including "---".
+const (
+	intro          = "age-encryption.org/v1\n"
+	stanzaPrefix   = "->"
+	footerPrefix   = "---"
+	columnsPerLine = 64
+	bytesPerLine   = columnsPerLine / 4 * 3
+)
+
+var (
+	errHeader  = errors.New("age: malformed header")
+	errVersion = errors.New("age: unsupported version")
+)
+
+var b64 = base64.RawStdEncoding.Strict()
+
+// decodeString decodes an unpadded, canonical base64 string.
+// In contrast to the base64 package CR and LF are not ignored.
+func decodeString(s string) ([]byte, error) {
+	if strings.ContainsAny(s, "\r\n") {
+		return nil, errHeader
+	}
+	return b64.DecodeString(s)
+}
+
+// header is the parsed age header.
+type header struct {
+	stanzas []*Stanza
+	mac     []byte
+}
+
+// marshalWithoutMAC writes the header up to and including
+// the "---" - the input of the header MAC.
+func (h *header) marshalWithoutMAC(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString(intro)
+	for _, s := range h.stanzas {
+		buf.WriteString(stanzaPrefix)
+		for _, a := range append([]string{s.Type}, s.Args...) {
+			buf.WriteString(" " + a)
+		}
+		buf.WriteString("\n")
+
+		body := b64.EncodeToString(s.Body)
+		for len(body) >= columnsPerLine {
+			buf.WriteString(body[:columnsPerLine] + "\n")
+			body = body[columnsPerLine:]
+		}
+		buf.WriteString(body + "\n")
+	}
+	buf.WriteString(footerPrefix)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// marshal writes the header including the MAC.
+func (h *header) marshal(w io.Writer) error {
+	if err := h.marshalWithoutMAC(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, " "+b64.EncodeToString(h.mac)+"\n")
+	return err
+}
+
+// parseHeader reads the header from r. The returned bufio.Reader
+// contains the (buffered) remaining data - the payload.
+func parseHeader(r io.Reader) (*header, *bufio.Reader, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, nil, errHeader
+	}
+	if line != intro {
+		if strings.HasPrefix(line, "age-encryption.org/") {
+			return nil, nil, errVersion
+		}
+		return nil, nil, errHeader
+	}
+
+	h := new(header)
+	for {
+		line, err = br.ReadString('\n')
+		if err != nil {
+			return nil, nil, errHeader
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if strings.HasPrefix(line, footerPrefix) {
+			args := strings.Split(line, " ")
+			if len(args) != 2 || args[0] != footerPrefix {
+				return nil, nil, errHeader
+			}
+			if h.mac, err = decodeString(args[1]); err != nil || len(h.mac) != 32 {
+				return nil, nil, errHeader
+			}
+			return h, br, nil
+		}
+
+		args := strings.Split(line, " ")
+		if len(args) < 2 || args[0] != stanzaPrefix {
+			return nil, nil, errHeader
+		}
+		for _, a := range args[1:] {
+			if !isValidString(a) {
+				return nil, nil, errHeader
+			}
+		}
+		s := &Stanza{Type: args[1], Args: args[2:]}
+		for {
+			line, err = br.ReadString('\n')
+			if err != nil {
+				return nil, nil, errHeader
+			}
+			b, err := decodeString(strings.TrimSuffix(line, "\n"))
+			if err != nil || len(b) > bytesPerLine {
+				return nil, nil, errHeader
+			}
+			s.Body = append(s.Body, b...)
+			if len(b) < bytesPerLine {
+				break // the body ends with a short line
+			}
+		}
+		h.stanzas = append(h.stanzas, s)
+	}
+}
+
+// isValidString returns true if s is not empty and
+// consists of printable ASCII characters only.
+func isValidString(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c < 33 || c > 126 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package age
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"strconv"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptLabel    = "age-encryption.org/v1/scrypt"
+	scryptSaltSize = 16
+
+	// DefaultScryptWorkFactor is the default base-2 logarithm of the
+	// scrypt cost parameter N used for encryption and the max. value
+	// accepted for decryption by default.
+	DefaultScryptWorkFactor = 18
+	defaultMaxWorkFactor    = 22
+)
+
+var (
+	errPassphrase       = errors.New("age: empty passphrase")
+	errWorkFactor       = errors.New("age: invalid scrypt work factor")
+	errScryptStanza     = errors.New("age: invalid scrypt stanza")
+	errScryptNotAlone   = errors.New("age: an scrypt stanza must be the only stanza")
+	errWorkFactorTooBig = errors.New("age: scrypt work factor too large")
+)
+
+// ScryptRecipient encrypts the file key with a key derived
+// from a passphrase using scrypt. An age file encrypted for
+// an ScryptRecipient must not have any other recipient.
+type ScryptRecipient struct {
+	passphrase []byte
+	workFactor int
+}
+
+// NewScryptRecipient returns a new *age.ScryptRecipient for
+// the passphrase. The passphrase must not be empty.
+func NewScryptRecipient(passphrase string) (*ScryptRecipient, error) {
+	if len(passphrase) == 0 {
+		return nil, errPassphrase
+	}
+	return &ScryptRecipient{passphrase: []byte(passphrase), workFactor: DefaultScryptWorkFactor}, nil
+}
+
+// SetWorkFactor sets the base-2 logarithm of the scrypt cost
+// parameter N. It panics if logN is not between 1 and 30.
+func (r *ScryptRecipient) SetWorkFactor(logN int) {
+	if logN < 1 || logN > 30 {
+		panic(errWorkFactor)
+	}
+	r.workFactor = logN
+}
+
+// Wrap encrypts the file key using a random salt.
+func (r *ScryptRecipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	salt := make([]byte, scryptSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(r.passphrase, append([]byte(scryptLabel), salt...), 1<<uint(r.workFactor), 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	body, err := aeadEncrypt(key, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{b64.EncodeToString(salt), strconv.Itoa(r.workFactor)}
+	return []*Stanza{{Type: "scrypt", Args: args, Body: body}}, nil
+}
+
+// ScryptIdentity decrypts file keys encrypted for a passphrase.
+type ScryptIdentity struct {
+	passphrase    []byte
+	maxWorkFactor int
+}
+
+// NewScryptIdentity returns a new *age.ScryptIdentity for
+// the passphrase. The passphrase must not be empty.
+func NewScryptIdentity(passphrase string) (*ScryptIdentity, error) {
+	if len(passphrase) == 0 {
+		return nil, errPassphrase
+	}
+	return &ScryptIdentity{passphrase: []byte(passphrase), maxWorkFactor: defaultMaxWorkFactor}, nil
+}
+
+// SetMaxWorkFactor sets the max. base-2 logarithm of the scrypt
+// cost parameter N accepted by Unwrap. Larger values make the
+// decryption of untrusted files expensive. It panics if logN is
+// not between 1 and 30.
+func (i *ScryptIdentity) SetMaxWorkFactor(logN int) {
+	if logN < 1 || logN > 30 {
+		panic(errWorkFactor)
+	}
+	i.maxWorkFactor = logN
+}
+
+// Unwrap decrypts the file key from the scrypt stanza. It returns
+// an error if the header contains an scrypt stanza and any other
+// stanza.
+func (i *ScryptIdentity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type == "scrypt" && len(stanzas) != 1 {
+			return nil, errScryptNotAlone
+		}
+	}
+	if len(stanzas) != 1 || stanzas[0].Type != "scrypt" {
+		return nil, ErrIncorrectIdentity
+	}
+	return i.unwrap(stanzas[0])
+}
+
+func (i *ScryptIdentity) unwrap(s *Stanza) ([]byte, error) {
+	if len(s.Args) != 2 {
+		return nil, errScryptStanza
+	}
+	salt, err := decodeString(s.Args[0])
+	if err != nil || len(salt) != scryptSaltSize {
+		return nil, errScryptStanza
+	}
+	logN, err := parseWorkFactor(s.Args[1])
+	if err != nil {
+		return nil, err
+	}
+	if logN > i.maxWorkFactor {
+		return nil, errWorkFactorTooBig
+	}
+
+	key, err := scrypt.Key(i.passphrase, append([]byte(scryptLabel), salt...), 1<<uint(logN), 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aeadDecrypt(key, s.Body)
+	if err == errFileKeySize {
+		return nil, errScryptStanza
+	}
+	if err != nil {
+		return nil, ErrIncorrectIdentity
+	}
+	return fileKey, nil
+}
+
+// parseWorkFactor parses the decimal work factor. Only
+// canonical values between 1 and 30 are accepted.
+func parseWorkFactor(s string) (int, error) {
+	if len(s) == 0 || len(s) > 2 || s[0] == '0' {
+		return 0, errWorkFactor
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, errWorkFactor
+		}
+	}
+	logN, _ := strconv.Atoi(s)
+	if logN > 30 {
+		return 0, errWorkFactor
+	}
+	return logN, nil
+}
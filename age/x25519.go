@@ -0,0 +1,162 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package age
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+const x25519Label = "age-encryption.org/v1/X25519"
+
+var (
+	errX25519Key    = errors.New("age: invalid X25519 key")
+	errX25519Stanza = errors.New("age: invalid X25519 stanza")
+)
+
+// X25519Recipient encrypts the file key for an X25519 public key.
+// Its string encoding is the Bech32 encoding with the "age" prefix.
+type X25519Recipient struct {
+	publicKey [32]byte
+}
+
+// ParseX25519Recipient parses the Bech32 encoded X25519 public key s
+// - e.g. "age1...".
+func ParseX25519Recipient(s string) (*X25519Recipient, error) {
+	hrp, key, err := bech32Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if hrp != "age" || len(key) != curve25519.PointSize {
+		return nil, errX25519Key
+	}
+	r := new(X25519Recipient)
+	copy(r.publicKey[:], key)
+	return r, nil
+}
+
+// Wrap encrypts the file key using an ephemeral X25519 key.
+func (r *X25519Recipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, ephemeral); err != nil {
+		return nil, err
+	}
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := curve25519.X25519(ephemeral, r.publicKey[:])
+	if err != nil {
+		return nil, err
+	}
+
+	key := hkdfKey(secret, append(share, r.publicKey[:]...), x25519Label)
+	body, err := aeadEncrypt(key, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return []*Stanza{{Type: "X25519", Args: []string{b64.EncodeToString(share)}, Body: body}}, nil
+}
+
+// String returns the Bech32 encoding of the public key.
+func (r *X25519Recipient) String() string {
+	s, _ := bech32Encode("age", r.publicKey[:])
+	return s
+}
+
+// X25519Identity decrypts file keys encrypted for its public key.
+// Its string encoding is the upper-case Bech32 encoding with the
+// "AGE-SECRET-KEY-" prefix.
+type X25519Identity struct {
+	secretKey, publicKey [32]byte
+}
+
+// GenerateX25519Identity returns a new random X25519 identity.
+func GenerateX25519Identity() (*X25519Identity, error) {
+	secretKey := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, secretKey); err != nil {
+		return nil, err
+	}
+	return newX25519Identity(secretKey)
+}
+
+// ParseX25519Identity parses the Bech32 encoded X25519 secret key s
+// - e.g. "AGE-SECRET-KEY-1...".
+func ParseX25519Identity(s string) (*X25519Identity, error) {
+	hrp, key, err := bech32Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if hrp != "age-secret-key-" || len(key) != curve25519.ScalarSize {
+		return nil, errX25519Key
+	}
+	return newX25519Identity(key)
+}
+
+func newX25519Identity(secretKey []byte) (*X25519Identity, error) {
+	publicKey, err := curve25519.X25519(secretKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	i := new(X25519Identity)
+	copy(i.secretKey[:], secretKey)
+	copy(i.publicKey[:], publicKey)
+	return i, nil
+}
+
+// Recipient returns the X25519Recipient of the identity.
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{publicKey: i.publicKey}
+}
+
+// String returns the Bech32 encoding of the secret key.
+func (i *X25519Identity) String() string {
+	s, _ := bech32Encode("age-secret-key-", i.secretKey[:])
+	return strings.ToUpper(s)
+}
+
+// Unwrap decrypts the file key from the first X25519 stanza
+// encrypted for the identity.
+func (i *X25519Identity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != "X25519" {
+			continue
+		}
+		fileKey, err := i.unwrap(s)
+		if err == ErrIncorrectIdentity {
+			continue
+		}
+		return fileKey, err
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+func (i *X25519Identity) unwrap(s *Stanza) ([]byte, error) {
+	if len(s.Args) != 1 {
+		return nil, errX25519Stanza
+	}
+	share, err := decodeString(s.Args[0])
+	if err != nil || len(share) != curve25519.PointSize {
+		return nil, errX25519Stanza
+	}
+	secret, err := curve25519.X25519(i.secretKey[:], share)
+	if err != nil {
+		return nil, errX25519Stanza // low-order point
+	}
+
+	key := hkdfKey(secret, append(share, i.publicKey[:]...), x25519Label)
+	fileKey, err := aeadDecrypt(key, s.Body)
+	if err == errFileKeySize {
+		return nil, errX25519Stanza
+	}
+	if err != nil {
+		return nil, ErrIncorrectIdentity
+	}
+	return fileKey, nil
+}
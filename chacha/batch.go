@@ -0,0 +1,309 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// maxBatchSize is the max. size of a message en/decrypted using the
+// SIMD lanes of the batch functions. Larger messages are processed
+// by xorKeyStream - the AVX2 implementation of xorKeyStream is faster
+// for messages with 4 or more blocks.
+const maxBatchSize = 3 * 64
+
+// A Message is one en/decryption of a batch. The Src is
+// en/decrypted to the Dst using the Nonce. Dst and Src may
+// be the same slice but otherwise must not overlap. The Dst
+// must not overlap the Dst or Src of any other message.
+type Message struct {
+	Dst, Src, Nonce []byte
+}
+
+// XORKeyStreamBatch crypts the Src of every message to its Dst using the nonce
+// of the message and the key. It is equivalent to calling XORKeyStream for every
+// message but the keystream of small messages is generated for up to 8 messages
+// at once. The XChaCha sub-keys are derived using HChaCha20Batch. Different
+// messages must not overlap. The rounds argument specifies the number of
+// rounds - valid values are 8, 12 or 20.
+// The nonce of every message must be NonceSize, INonceSize or XNonceSize bytes long.
+// The function panics for the same arguments as XORKeyStream.
+func XORKeyStreamBatch(msgs []Message, key []byte, rounds int) {
+	if err := XORKeyStreamBatchChecked(msgs, key, rounds); err != nil {
+		panic(err)
+	}
+}
+
+// XORKeyStreamBatchChecked behaves like XORKeyStreamBatch but returns the same errors
+// as XORKeyStreamChecked instead of panicking. It also returns ErrOverlap if the Dst of
+// a message overlaps the Dst or Src of another message. Different messages may share
+// the same Src. All messages are checked before any message is en/decrypted - so if a
+// non-nil error is returned no Dst is modified.
+func XORKeyStreamBatchChecked(msgs []Message, key []byte, rounds int) error {
+	if len(key) != KeySize {
+		return ErrKeySize
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		if n := len(m.Nonce); n != NonceSize && n != INonceSize && n != XNonceSize {
+			return ErrNonceSize
+		}
+		if err := checkXORKeyStream(m.Dst, m.Src, m.Nonce, rounds, 0); err != nil {
+			return err
+		}
+	}
+	if len(msgs) > 1 && overlap(msgs) {
+		return ErrOverlap
+	}
+
+	var b batch
+	var Key [32]byte
+	var base, state [64]byte
+	var zero [16]byte
+	copy(Key[:], key)
+	initialize(&base, key, &zero)
+	b.rounds = rounds
+	for len(msgs) > 0 {
+		group := msgs
+		if len(group) > len(b.subKeys) {
+			group = group[:len(b.subKeys)]
+		}
+		msgs = msgs[len(group):]
+
+		// derive the sub-keys of all XChaCha messages of the group at once
+		n := 0
+		for i := range group {
+			if len(group[i].Nonce) == XNonceSize {
+				copy(b.hNonces[n][:], group[i].Nonce[:16])
+				n++
+			}
+		}
+		hChaChaBatch(b.subKeys[:n], b.hNonces[:n], &Key, 20)
+
+		n = 0
+		for i := range group {
+			m := &group[i]
+			state = base
+			switch len(m.Nonce) {
+			case XNonceSize:
+				copy(state[16:48], b.subKeys[n][:])
+				copy(state[56:], m.Nonce[16:])
+				n++
+			case INonceSize:
+				copy(state[52:], m.Nonce)
+			default:
+				copy(state[56:], m.Nonce)
+			}
+			b.xorKeyStream(m.Dst, m.Src, &state)
+		}
+	}
+	b.flush()
+	b.wipe()
+	wipe(Key[:])
+	wipe(base[:])
+	wipe(state[:])
+	return nil
+}
+
+// overlap reports whether the Dst of any message overlaps the Dst or
+// Src of another message. The Dst and Src of every message must already
+// be either the same or disjoint.
+func overlap(msgs []Message) bool {
+	// Usually the messages are stored one after another. Then
+	// no message starts before the previous message ends.
+	var end uintptr
+	for i := range msgs {
+		m := &msgs[i]
+		if len(m.Src) == 0 {
+			continue
+		}
+		lo, hi := addr(m.Dst), addr(m.Src)
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo < end {
+			return overlapSorted(msgs)
+		}
+		end = hi + uintptr(len(m.Src))
+	}
+	return false
+}
+
+// overlapSorted is like overlap but sorts the Dst and Src
+// of all messages by their address.
+func overlapSorted(msgs []Message) bool {
+	regions := make(memRegions, 0, 2*len(msgs))
+	for i := range msgs {
+		m := &msgs[i]
+		if len(m.Src) == 0 {
+			continue
+		}
+		dst := m.Dst[:len(m.Src)]
+		regions = append(regions, memRegion{start: addr(dst), end: addr(dst) + uintptr(len(dst)), isDst: true})
+		if !anyOverlap(dst, m.Src) {
+			regions = append(regions, memRegion{start: addr(m.Src), end: addr(m.Src) + uintptr(len(m.Src))})
+		}
+	}
+	sort.Sort(regions)
+
+	// Two regions overlap if one starts before the other ends. So every
+	// region must start after all previous Dst regions end and a Dst region
+	// must start after all previous regions end.
+	var end, dstEnd uintptr
+	for _, r := range regions {
+		if r.start < dstEnd || (r.isDst && r.start < end) {
+			return true
+		}
+		if r.end > end {
+			end = r.end
+		}
+		if r.isDst && r.end > dstEnd {
+			dstEnd = r.end
+		}
+	}
+	return false
+}
+
+// memRegion is the memory [start, end) of a Dst or Src.
+type memRegion struct {
+	start, end uintptr
+	isDst      bool
+}
+
+// memRegions sorts memory regions by their start address.
+type memRegions []memRegion
+
+func (r memRegions) Len() int           { return len(r) }
+func (r memRegions) Less(i, j int) bool { return r[i].start < r[j].start }
+func (r memRegions) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// HChaCha20Batch generates 32 pseudo-random bytes from every nonce and the key
+// using HChaCha20 and writes them to the corresponding out. It is equivalent
+// to calling HChaCha20 for every nonce but computes up to 8 (AVX2) or 4 (SSSE3)
+// outputs at once. This function panics if len(outs) != len(nonces).
+func HChaCha20Batch(outs [][32]byte, nonces [][16]byte, key *[32]byte) {
+	if len(outs) != len(nonces) {
+		panic("chacha20/chacha: number of outputs doesn't match number of nonces")
+	}
+	hChaChaBatch(outs, nonces, key, 20)
+}
+
+func hChaChaBatch(outs [][32]byte, nonces [][16]byte, key *[32]byte, rounds int) {
+	var states [8][64]byte
+	var out [8 * 64]byte
+	for len(nonces) > 0 {
+		n := len(nonces)
+		if n > len(states) {
+			n = len(states)
+		}
+		if n == 1 {
+			hChaCha(&outs[0], &nonces[0], key, rounds)
+			break
+		}
+		for i := 0; i < n; i++ {
+			initialize(&states[i], key[:], &nonces[i])
+		}
+		blocks(&out, &states, n, rounds)
+
+		// HChaCha returns the first and the last row of the
+		// permutation - without the addition of the state.
+		for i := 0; i < n; i++ {
+			block, state := out[i*64:], states[i][:]
+			for j := 0; j < 16; j += 4 {
+				v := binary.LittleEndian.Uint32(block[j:]) - binary.LittleEndian.Uint32(state[j:])
+				binary.LittleEndian.PutUint32(outs[i][j:], v)
+				v = binary.LittleEndian.Uint32(block[48+j:]) - binary.LittleEndian.Uint32(state[48+j:])
+				binary.LittleEndian.PutUint32(outs[i][16+j:], v)
+			}
+		}
+		outs, nonces = outs[n:], nonces[n:]
+	}
+	for i := range states {
+		wipe(states[i][:])
+	}
+	wipe(out[:])
+}
+
+// batch collects up to 8 blocks of independent messages.
+type batch struct {
+	states   [8][64]byte // the state of every block
+	dst, src [8][]byte
+	out      [8 * 64]byte
+	n        int
+	rounds   int
+
+	subKeys [8][32]byte // the XChaCha sub-keys of the current group of messages
+	hNonces [8][16]byte
+}
+
+// xorKeyStream en/decrypts a message starting at the given state.
+// Small messages are split into blocks and added to the batch while
+// large messages are en/decrypted directly.
+func (b *batch) xorKeyStream(dst, src []byte, state *[64]byte) {
+	if len(src) > maxBatchSize {
+		var block [64]byte
+		xorKeyStream(dst, src, &block, state, b.rounds)
+		wipe(block[:])
+		return
+	}
+	for ctr := uint32(0); len(src) > 0; ctr++ {
+		binary.LittleEndian.PutUint32(state[48:], ctr) // the counter of small messages fits into 32 bits
+		n := 64
+		if len(src) < n {
+			n = len(src)
+		}
+		b.add(dst[:n], src[:n], state)
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// add adds the block specified by state to the batch.
+// If the batch is full it is flushed.
+func (b *batch) add(dst, src []byte, state *[64]byte) {
+	b.states[b.n] = *state
+	b.dst[b.n], b.src[b.n] = dst, src
+	b.n++
+	if b.n == len(b.states) {
+		b.flush()
+	}
+}
+
+// flush computes the keystream of all blocks of the batch
+// and xors it with the src of the blocks.
+func (b *batch) flush() {
+	if b.n == 0 {
+		return
+	}
+	blocks(&(b.out), &(b.states), b.n, b.rounds)
+	for i := 0; i < b.n; i++ {
+		xorBytes(b.dst[i], b.src[i], b.out[i*64:])
+		b.dst[i], b.src[i] = nil, nil
+	}
+	b.n = 0
+}
+
+func (b *batch) wipe() {
+	for i := range b.states {
+		wipe(b.states[i][:])
+	}
+	for i := range b.subKeys {
+		wipe(b.subKeys[i][:])
+	}
+	wipe(b.out[:])
+}
+
+// xorBytes xors the len(src) bytes of src and keyStream
+// and writes the result to dst.
+func xorBytes(dst, src, keyStream []byte) {
+	n := len(src) &^ 7
+	for i := 0; i < n; i += 8 {
+		v := binary.LittleEndian.Uint64(src[i:]) ^ binary.LittleEndian.Uint64(keyStream[i:])
+		binary.LittleEndian.PutUint64(dst[i:], v)
+	}
+	for i := n; i < len(src); i++ {
+		dst[i] = src[i] ^ keyStream[i]
+	}
+}
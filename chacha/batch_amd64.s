@@ -0,0 +1,266 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build amd64,!gccgo,!appengine,!nacl
+
+#include "const.s"
+#include "macro.s"
+
+// The blocks functions compute one keystream block for each of 4 (SSSE3)
+// or 8 (AVX2) independent ChaCha states at once. In contrast to the
+// xorKeyStream functions every SIMD register holds the same state word
+// of all states - one state per 32 bit lane. So the states are transposed
+// when loaded and the keystream blocks are transposed before stored.
+// The 16 state words don't fit into the registers together with the
+// temp. registers and the rotate constants - so they are kept on the stack
+// and two quarter-rounds are computed at once.
+
+// TRANSPOSE_SSE transposes the 4x4 matrix of 32 bit values
+// in the XMM registers v0 - v3. The XMM registers t0 and t1
+// are used as temp. registers.
+#define TRANSPOSE_SSE(v0, v1, v2, v3, t0, t1) \
+	MOVO       v0, t0; \
+	PUNPCKLLQ  v1, v0; \
+	PUNPCKHLQ  v1, t0; \
+	MOVO       v2, t1; \
+	PUNPCKLLQ  v3, v2; \
+	PUNPCKHLQ  v3, t1; \
+	MOVO       v0, v1; \
+	PUNPCKLQDQ v2, v0; \
+	PUNPCKHQDQ v2, v1; \
+	MOVO       t0, v2; \
+	PUNPCKLQDQ t1, v2; \
+	MOVO       t0, v3; \
+	PUNPCKHQDQ t1, v3
+
+// TRANSPOSE_AVX transposes the two 4x4 matrices of 32 bit values
+// in the 128 bit lanes of the AVX2 registers v0 - v3. The AVX2
+// registers t0 and t1 are used as temp. registers.
+#define TRANSPOSE_AVX(v0, v1, v2, v3, t0, t1) \
+	VPUNPCKLDQ  v1, v0, t0; \
+	VPUNPCKHDQ  v1, v0, v1; \
+	VPUNPCKLDQ  v3, v2, t1; \
+	VPUNPCKHDQ  v3, v2, v3; \
+	VPUNPCKLQDQ t1, t0, v0; \
+	VPUNPCKLQDQ v3, v1, v2; \
+	VPUNPCKHQDQ v3, v1, v3; \
+	VPUNPCKHQDQ t1, t0, v1
+
+// BATCH_QROUND_SSSE3 performs two ChaCha quarter-rounds on the state
+// words a0, b0, c0, d0 and a1, b1, c1, d1 stored in memory.
+#define BATCH_QROUND_SSSE3(a0, b0, c0, d0, a1, b1, c1, d1) \
+	MOVOU a0, X0;                                       \
+	MOVOU b0, X1;                                       \
+	MOVOU c0, X2;                                       \
+	MOVOU d0, X3;                                       \
+	MOVOU a1, X4;                                       \
+	MOVOU b1, X5;                                       \
+	MOVOU c1, X6;                                       \
+	MOVOU d1, X7;                                       \
+	CHACHA_QROUND_SSSE3(X0, X1, X2, X3, X8, X10, X11);  \
+	CHACHA_QROUND_SSSE3(X4, X5, X6, X7, X9, X10, X11);  \
+	MOVOU X0, a0;                                       \
+	MOVOU X1, b0;                                       \
+	MOVOU X2, c0;                                       \
+	MOVOU X3, d0;                                       \
+	MOVOU X4, a1;                                       \
+	MOVOU X5, b1;                                       \
+	MOVOU X6, c1;                                       \
+	MOVOU X7, d1
+
+// BATCH_QROUND_AVX2 performs two ChaCha quarter-rounds on the state
+// words a0, b0, c0, d0 and a1, b1, c1, d1 stored in memory.
+#define BATCH_QROUND_AVX2(a0, b0, c0, d0, a1, b1, c1, d1) \
+	VMOVDQU a0, Y0;                                   \
+	VMOVDQU b0, Y1;                                   \
+	VMOVDQU c0, Y2;                                   \
+	VMOVDQU d0, Y3;                                   \
+	VMOVDQU a1, Y4;                                   \
+	VMOVDQU b1, Y5;                                   \
+	VMOVDQU c1, Y6;                                   \
+	VMOVDQU d1, Y7;                                   \
+	CHACHA_QROUND_AVX(Y0, Y1, Y2, Y3, Y8, Y10, Y11);  \
+	CHACHA_QROUND_AVX(Y4, Y5, Y6, Y7, Y9, Y10, Y11);  \
+	VMOVDQU Y0, a0;                                   \
+	VMOVDQU Y1, b0;                                   \
+	VMOVDQU Y2, c0;                                   \
+	VMOVDQU Y3, d0;                                   \
+	VMOVDQU Y4, a1;                                   \
+	VMOVDQU Y5, b1;                                   \
+	VMOVDQU Y6, c1;                                   \
+	VMOVDQU Y7, d1
+
+// LOAD_LANES_SSE loads 16 bytes at off of the 4 states
+// at src into the XMM registers v0 - v3.
+#define LOAD_LANES_SSE(src, off, v0, v1, v2, v3) \
+	MOVOU (0+off)(src), v0;   \
+	MOVOU (64+off)(src), v1;  \
+	MOVOU (128+off)(src), v2; \
+	MOVOU (192+off)(src), v3
+
+// STORE_LANES_SSE writes the 4 XMM registers v0 - v3 holding
+// 16 bytes of the 4 keystream blocks to dst at off.
+#define STORE_LANES_SSE(dst, off, v0, v1, v2, v3) \
+	MOVOU v0, (0+off)(dst);   \
+	MOVOU v1, (64+off)(dst);  \
+	MOVOU v2, (128+off)(dst); \
+	MOVOU v3, (192+off)(dst)
+
+// LOAD_LANES_AVX2 loads 16 bytes at off of the 8 states at src
+// into the AVX2 registers v0 - v3. The states 0 - 3 occupy the
+// lower 128 bit lanes and the states 4 - 7 the upper lanes.
+// The XMM registers x0 - x3 must be the lower halves of v0 - v3.
+#define LOAD_LANES_AVX2(src, off, v0, v1, v2, v3, x0, x1, x2, x3) \
+	VMOVDQU     (0+off)(src), x0;         \
+	VMOVDQU     (64+off)(src), x1;        \
+	VMOVDQU     (128+off)(src), x2;       \
+	VMOVDQU     (192+off)(src), x3;       \
+	VINSERTI128 $1, (256+off)(src), v0, v0; \
+	VINSERTI128 $1, (320+off)(src), v1, v1; \
+	VINSERTI128 $1, (384+off)(src), v2, v2; \
+	VINSERTI128 $1, (448+off)(src), v3, v3
+
+// STORE_LANES_AVX2 writes the 4 AVX2 registers v0 - v3 holding
+// 16 bytes of the 8 keystream blocks to dst at off. The lower
+// 128 bit lanes belong to the blocks 0 - 3 and the upper lanes
+// to the blocks 4 - 7.
+// The XMM registers x0 - x3 must be the lower halves of v0 - v3.
+#define STORE_LANES_AVX2(dst, off, v0, v1, v2, v3, x0, x1, x2, x3) \
+	VMOVDQU      x0, (0+off)(dst);      \
+	VMOVDQU      x1, (64+off)(dst);     \
+	VMOVDQU      x2, (128+off)(dst);    \
+	VMOVDQU      x3, (192+off)(dst);    \
+	VEXTRACTI128 $1, v0, (256+off)(dst); \
+	VEXTRACTI128 $1, v1, (320+off)(dst); \
+	VEXTRACTI128 $1, v2, (384+off)(dst); \
+	VEXTRACTI128 $1, v3, (448+off)(dst)
+
+// TRANSPOSE_IN_SSE transposes 16 bytes at off of the 4 states
+// at src and writes the 4 state words to the stack at 4*off.
+#define TRANSPOSE_IN_SSE(src, off) \
+	LOAD_LANES_SSE(src, off, X0, X1, X2, X3); \
+	TRANSPOSE_SSE(X0, X1, X2, X3, X4, X5);    \
+	MOVOU X0, (4*off)(SP);                    \
+	MOVOU X1, (4*off+16)(SP);                 \
+	MOVOU X2, (4*off+32)(SP);                 \
+	MOVOU X3, (4*off+48)(SP)
+
+// TRANSPOSE_OUT_SSE transposes the 4 state words on the stack
+// at 4*off, adds 16 bytes at off of the 4 states at src and
+// writes the result to dst at off.
+#define TRANSPOSE_OUT_SSE(dst, src, off) \
+	MOVOU (4*off)(SP), X0;                    \
+	MOVOU (4*off+16)(SP), X1;                 \
+	MOVOU (4*off+32)(SP), X2;                 \
+	MOVOU (4*off+48)(SP), X3;                 \
+	TRANSPOSE_SSE(X0, X1, X2, X3, X4, X5);    \
+	LOAD_LANES_SSE(src, off, X4, X5, X6, X7); \
+	PADDL X4, X0;                             \
+	PADDL X5, X1;                             \
+	PADDL X6, X2;                             \
+	PADDL X7, X3;                             \
+	STORE_LANES_SSE(dst, off, X0, X1, X2, X3)
+
+// TRANSPOSE_IN_AVX2 transposes 16 bytes at off of the 8 states
+// at src and writes the 4 state words to the stack at 8*off.
+#define TRANSPOSE_IN_AVX2(src, off) \
+	LOAD_LANES_AVX2(src, off, Y0, Y1, Y2, Y3, X0, X1, X2, X3); \
+	TRANSPOSE_AVX(Y0, Y1, Y2, Y3, Y4, Y5);                     \
+	VMOVDQU Y0, (8*off)(SP);                                   \
+	VMOVDQU Y1, (8*off+32)(SP);                                \
+	VMOVDQU Y2, (8*off+64)(SP);                                \
+	VMOVDQU Y3, (8*off+96)(SP)
+
+// TRANSPOSE_OUT_AVX2 transposes the 4 state words on the stack
+// at 8*off, adds 16 bytes at off of the 8 states at src and
+// writes the result to dst at off.
+#define TRANSPOSE_OUT_AVX2(dst, src, off) \
+	VMOVDQU (8*off)(SP), Y0;                                   \
+	VMOVDQU (8*off+32)(SP), Y1;                                \
+	VMOVDQU (8*off+64)(SP), Y2;                                \
+	VMOVDQU (8*off+96)(SP), Y3;                                \
+	TRANSPOSE_AVX(Y0, Y1, Y2, Y3, Y4, Y5);                     \
+	LOAD_LANES_AVX2(src, off, Y4, Y5, Y6, Y7, X4, X5, X6, X7); \
+	VPADDD Y4, Y0, Y0;                                         \
+	VPADDD Y5, Y1, Y1;                                         \
+	VPADDD Y6, Y2, Y2;                                         \
+	VPADDD Y7, Y3, Y3;                                         \
+	STORE_LANES_AVX2(dst, off, Y0, Y1, Y2, Y3, X0, X1, X2, X3)
+
+// func blocksSSSE3(out *byte, states *[64]byte, rounds int)
+TEXT ·blocksSSSE3(SB), 4, $256-24
+	MOVQ out+0(FP), DI
+	MOVQ states+8(FP), SI
+	MOVQ rounds+16(FP), CX
+
+	TRANSPOSE_IN_SSE(SI, 0)
+	TRANSPOSE_IN_SSE(SI, 16)
+	TRANSPOSE_IN_SSE(SI, 32)
+	TRANSPOSE_IN_SSE(SI, 48)
+
+	MOVOU ·rol16<>(SB), X10
+	MOVOU ·rol8<>(SB), X11
+
+loop:
+	BATCH_QROUND_SSSE3(0(SP), 64(SP), 128(SP), 192(SP), 16(SP), 80(SP), 144(SP), 208(SP))
+	BATCH_QROUND_SSSE3(32(SP), 96(SP), 160(SP), 224(SP), 48(SP), 112(SP), 176(SP), 240(SP))
+	BATCH_QROUND_SSSE3(0(SP), 80(SP), 160(SP), 240(SP), 16(SP), 96(SP), 176(SP), 192(SP))
+	BATCH_QROUND_SSSE3(32(SP), 112(SP), 128(SP), 208(SP), 48(SP), 64(SP), 144(SP), 224(SP))
+	SUBQ $2, CX
+	JNZ  loop
+
+	TRANSPOSE_OUT_SSE(DI, SI, 0)
+	TRANSPOSE_OUT_SSE(DI, SI, 16)
+	TRANSPOSE_OUT_SSE(DI, SI, 32)
+	TRANSPOSE_OUT_SSE(DI, SI, 48)
+
+	// Clear the keystream on the stack
+	PXOR X0, X0
+	MOVQ $0, CX
+
+clear_loop:
+	MOVOU X0, 0(SP)(CX*1)
+	ADDQ  $16, CX
+	CMPQ  CX, $256
+	JB    clear_loop
+	RET
+
+// func blocksAVX2(out *byte, states *[64]byte, rounds int)
+TEXT ·blocksAVX2(SB), 4, $512-24
+	MOVQ out+0(FP), DI
+	MOVQ states+8(FP), SI
+	MOVQ rounds+16(FP), CX
+
+	TRANSPOSE_IN_AVX2(SI, 0)
+	TRANSPOSE_IN_AVX2(SI, 16)
+	TRANSPOSE_IN_AVX2(SI, 32)
+	TRANSPOSE_IN_AVX2(SI, 48)
+
+	VMOVDQU ·rol16_AVX2<>(SB), Y10
+	VMOVDQU ·rol8_AVX2<>(SB), Y11
+
+loop:
+	BATCH_QROUND_AVX2(0(SP), 128(SP), 256(SP), 384(SP), 32(SP), 160(SP), 288(SP), 416(SP))
+	BATCH_QROUND_AVX2(64(SP), 192(SP), 320(SP), 448(SP), 96(SP), 224(SP), 352(SP), 480(SP))
+	BATCH_QROUND_AVX2(0(SP), 160(SP), 320(SP), 480(SP), 32(SP), 192(SP), 352(SP), 384(SP))
+	BATCH_QROUND_AVX2(64(SP), 224(SP), 256(SP), 416(SP), 96(SP), 128(SP), 288(SP), 448(SP))
+	SUBQ $2, CX
+	JNZ  loop
+
+	TRANSPOSE_OUT_AVX2(DI, SI, 0)
+	TRANSPOSE_OUT_AVX2(DI, SI, 16)
+	TRANSPOSE_OUT_AVX2(DI, SI, 32)
+	TRANSPOSE_OUT_AVX2(DI, SI, 48)
+
+	// Clear the keystream on the stack
+	VPXOR Y0, Y0, Y0
+	MOVQ  $0, CX
+
+clear_loop:
+	VMOVDQU Y0, 0(SP)(CX*1)
+	ADDQ    $32, CX
+	CMPQ    CX, $512
+	JB      clear_loop
+	VZEROUPPER
+	RET
@@ -0,0 +1,295 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestXORKeyStreamBatch(t *testing.T) {
+	defer func(sse2, ssse3, avx, avx2 bool) {
+		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
+	}(useSSE2, useSSSE3, useAVX, useAVX2)
+
+	if useAVX2 {
+		t.Log("AVX2 version")
+		testXORKeyStreamBatch(t)
+		useAVX2 = false
+	}
+	if useAVX {
+		t.Log("AVX version")
+		testXORKeyStreamBatch(t)
+		useAVX = false
+	}
+	if useSSSE3 {
+		t.Log("SSSE3 version")
+		testXORKeyStreamBatch(t)
+		useSSSE3 = false
+	}
+	if useSSE2 {
+		t.Log("SSE2 version")
+		testXORKeyStreamBatch(t)
+		useSSE2 = false
+	}
+	t.Log("generic version")
+	testXORKeyStreamBatch(t)
+}
+
+func testXORKeyStreamBatch(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	sizes := []int{0, 1, 63, 64, 65, 100, 128, 255, 256, 257, 1000}
+
+	for _, rounds := range []int{8, 12, 20} {
+		for _, count := range []int{1, 3, 4, 5, 8, 9, 17, 33} {
+			msgs := make([]Message, count)
+			want := make([][]byte, count)
+			for i := range msgs {
+				nonceSize := []int{NonceSize, INonceSize, XNonceSize}[i%3]
+				nonce := make([]byte, nonceSize)
+				for j := range nonce {
+					nonce[j] = byte(i*7 + j)
+				}
+				src := make([]byte, sizes[(i*5+count)%len(sizes)])
+				for j := range src {
+					src[j] = byte(i + j)
+				}
+				want[i] = make([]byte, len(src))
+				XORKeyStream(want[i], src, nonce, key, rounds)
+
+				dst := src // en/decrypt the odd messages in-place
+				if i%2 == 0 {
+					dst = make([]byte, len(src))
+				}
+				msgs[i] = Message{Dst: dst, Src: src, Nonce: nonce}
+			}
+
+			XORKeyStreamBatch(msgs, key, rounds)
+			for i, m := range msgs {
+				if !bytes.Equal(m.Dst, want[i]) {
+					t.Fatalf("Rounds %d: count %d: message %d (size %d) mismatch:\n \t got:  %s\n \t want: %s", rounds, count, i, len(m.Dst), toHex(m.Dst), toHex(want[i]))
+				}
+			}
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	defer func(sse2, ssse3, avx, avx2 bool) {
+		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
+	}(useSSE2, useSSSE3, useAVX, useAVX2)
+
+	if useAVX2 {
+		t.Log("AVX2 version")
+		testBlocks(t)
+		useAVX2 = false
+	}
+	if useSSSE3 {
+		t.Log("SSSE3 version")
+		testBlocks(t)
+		useSSSE3 = false
+	}
+	t.Log("generic version")
+	testBlocks(t)
+}
+
+func testBlocks(t *testing.T) {
+	var states [8][64]byte
+	for i := range states {
+		for j := range states[i] {
+			states[i][j] = byte(255 - i*64 - j*5)
+		}
+	}
+
+	for _, rounds := range []int{8, 12, 20} {
+		var want [8 * 64]byte
+		for i := range states {
+			var block [64]byte
+			s := states[i]
+			keyStreamGeneric(block[:], &block, &s, rounds)
+			copy(want[i*64:], block[:])
+		}
+		for _, n := range []int{3, 4, 5, 8} {
+			var out [8 * 64]byte
+			in := states
+			blocks(&out, &states, n, rounds)
+			if !bytes.Equal(out[:n*64], want[:n*64]) {
+				t.Errorf("Rounds %d: blocks(%d) mismatch:\n \t got:  %s\n \t want: %s", rounds, n, toHex(out[:n*64]), toHex(want[:n*64]))
+			}
+			if in != states {
+				t.Errorf("Rounds %d: blocks(%d) modified the states", rounds, n)
+			}
+		}
+	}
+}
+
+func TestHChaCha20Batch(t *testing.T) {
+	defer func(sse2, ssse3, avx, avx2 bool) {
+		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
+	}(useSSE2, useSSSE3, useAVX, useAVX2)
+
+	if useAVX2 {
+		t.Log("AVX2 version")
+		testHChaCha20Batch(t)
+		useAVX2 = false
+	}
+	if useAVX {
+		t.Log("AVX version")
+		testHChaCha20Batch(t)
+		useAVX = false
+	}
+	if useSSSE3 {
+		t.Log("SSSE3 version")
+		testHChaCha20Batch(t)
+		useSSSE3 = false
+	}
+	if useSSE2 {
+		t.Log("SSE2 version")
+		testHChaCha20Batch(t)
+		useSSE2 = false
+	}
+	t.Log("generic version")
+	testHChaCha20Batch(t)
+}
+
+func testHChaCha20Batch(t *testing.T) {
+	var key [32]byte
+	for i := range key {
+		key[i] = byte(i * 3)
+	}
+	for _, count := range []int{0, 1, 2, 4, 5, 8, 9, 17} {
+		nonces := make([][16]byte, count)
+		for i := range nonces {
+			for j := range nonces[i] {
+				nonces[i][j] = byte(i*16 + j)
+			}
+		}
+		outs := make([][32]byte, count)
+		HChaCha20Batch(outs, nonces, &key)
+		for i := range outs {
+			var want [32]byte
+			HChaCha20(&want, &nonces[i], &key)
+			if outs[i] != want {
+				t.Errorf("Count %d: output %d mismatch:\n \t got:  %s\n \t want: %s", count, i, toHex(outs[i][:]), toHex(want[:]))
+			}
+		}
+	}
+
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("HChaCha20Batch accepted different numbers of outputs and nonces")
+		}
+	}()
+	HChaCha20Batch(make([][32]byte, 2), make([][16]byte, 3), &key)
+}
+
+func TestXORKeyStreamBatchErrors(t *testing.T) {
+	key := make([]byte, KeySize)
+	buf := make([]byte, 128)
+	valid := Message{Dst: make([]byte, 64), Src: make([]byte, 64), Nonce: make([]byte, NonceSize)}
+
+	for i, test := range []struct {
+		msg    Message
+		key    []byte
+		rounds int
+		err    error
+	}{
+		{msg: valid, key: key[:16], rounds: 20, err: ErrKeySize},
+		{msg: valid, key: key, rounds: 10, err: ErrRounds},
+		{msg: Message{Dst: buf, Src: buf, Nonce: make([]byte, 16)}, key: key, rounds: 20, err: ErrNonceSize},
+		{msg: Message{Dst: buf[:10], Src: buf, Nonce: make([]byte, NonceSize)}, key: key, rounds: 20, err: ErrShortBuffer},
+		{msg: Message{Dst: buf[1:], Src: buf[:100], Nonce: make([]byte, NonceSize)}, key: key, rounds: 20, err: ErrOverlap},
+	} {
+		msgs := []Message{valid, test.msg}
+		if err := XORKeyStreamBatchChecked(msgs, test.key, test.rounds); err != test.err {
+			t.Errorf("Test %d: XORKeyStreamBatchChecked returned %v - want %v", i, err, test.err)
+		}
+	}
+	for i, msgs := range [][]Message{
+		{valid, {Dst: buf[:64], Src: buf[:64], Nonce: valid.Nonce}, {Dst: buf[32:96], Src: buf[32:96], Nonce: valid.Nonce}},
+		{{Dst: buf[:64], Src: buf[:64], Nonce: valid.Nonce}, valid, {Dst: buf[64:], Src: buf[:64], Nonce: valid.Nonce}},
+		{{Dst: buf[64:], Src: buf[:64], Nonce: valid.Nonce}, {Dst: buf[:64], Src: buf[:64], Nonce: valid.Nonce}},
+		{{Dst: buf[:64], Src: make([]byte, 64), Nonce: valid.Nonce}, {Dst: buf[:64], Src: make([]byte, 64), Nonce: valid.Nonce}},
+	} {
+		if err := XORKeyStreamBatchChecked(msgs, key, 20); err != ErrOverlap {
+			t.Errorf("Test %d: XORKeyStreamBatchChecked returned %v - want %v", i, err, ErrOverlap)
+		}
+	}
+	for _, v := range buf {
+		if v != 0 {
+			t.Fatal("XORKeyStreamBatchChecked modified dst on error")
+		}
+	}
+
+	// Different messages may share the same Src and
+	// a Dst may end where the Dst of another message starts.
+	for i, msgs := range [][]Message{
+		{{Dst: buf[:64], Src: valid.Src, Nonce: valid.Nonce}, {Dst: buf[64:], Src: valid.Src, Nonce: valid.Nonce}},
+		{{Dst: buf[64:], Src: valid.Src, Nonce: valid.Nonce}, {Dst: buf[:64], Src: valid.Src, Nonce: valid.Nonce}},
+		{{Dst: buf[64:], Src: buf[64:], Nonce: valid.Nonce}, {Dst: buf[:64], Src: buf[:64], Nonce: valid.Nonce}},
+	} {
+		if err := XORKeyStreamBatchChecked(msgs, key, 20); err != nil {
+			t.Errorf("Test %d: XORKeyStreamBatchChecked returned %v", i, err)
+		}
+	}
+}
+
+func benchmarkXORKeyStreamBatch(b *testing.B, size, count, nonceSize int) {
+	key := make([]byte, KeySize)
+	msgs := make([]Message, count)
+	for i := range msgs {
+		buf := make([]byte, size)
+		msgs[i] = Message{Dst: buf, Src: buf, Nonce: make([]byte, nonceSize)}
+	}
+	b.SetBytes(int64(size * count))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		XORKeyStreamBatch(msgs, key, 20)
+	}
+}
+
+func benchmarkXORKeyStreamLoop(b *testing.B, size, count, nonceSize int) {
+	key := make([]byte, KeySize)
+	msgs := make([]Message, count)
+	for i := range msgs {
+		buf := make([]byte, size)
+		msgs[i] = Message{Dst: buf, Src: buf, Nonce: make([]byte, nonceSize)}
+	}
+	b.SetBytes(int64(size * count))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, m := range msgs {
+			XORKeyStream(m.Dst, m.Src, m.Nonce, key, 20)
+		}
+	}
+}
+
+func BenchmarkXORKeyStreamBatch_64(b *testing.B)  { benchmarkXORKeyStreamBatch(b, 64, 64, INonceSize) }
+func BenchmarkXORKeyStreamBatch_256(b *testing.B) { benchmarkXORKeyStreamBatch(b, 256, 64, INonceSize) }
+func BenchmarkXORKeyStreamLoop_64(b *testing.B)   { benchmarkXORKeyStreamLoop(b, 64, 64, INonceSize) }
+func BenchmarkXORKeyStreamLoop_256(b *testing.B)  { benchmarkXORKeyStreamLoop(b, 256, 64, INonceSize) }
+func BenchmarkXXORKeyStreamBatch_64(b *testing.B) { benchmarkXORKeyStreamBatch(b, 64, 64, XNonceSize) }
+func BenchmarkXXORKeyStreamBatch_256(b *testing.B) {
+	benchmarkXORKeyStreamBatch(b, 256, 64, XNonceSize)
+}
+func BenchmarkXXORKeyStreamLoop_64(b *testing.B)  { benchmarkXORKeyStreamLoop(b, 64, 64, XNonceSize) }
+func BenchmarkXXORKeyStreamLoop_256(b *testing.B) { benchmarkXORKeyStreamLoop(b, 256, 64, XNonceSize) }
+
+func benchmarkHChaCha20Batch(b *testing.B, count int) {
+	var key [32]byte
+	nonces := make([][16]byte, count)
+	outs := make([][32]byte, count)
+	b.SetBytes(int64(32 * count))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		HChaCha20Batch(outs, nonces, &key)
+	}
+}
+
+func BenchmarkHChaCha20Batch_8(b *testing.B)  { benchmarkHChaCha20Batch(b, 8) }
+func BenchmarkHChaCha20Batch_64(b *testing.B) { benchmarkHChaCha20Batch(b, 64) }
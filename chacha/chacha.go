@@ -34,13 +34,38 @@ var (
 )
 
 var (
-	errKeySize      = errors.New("chacha20/chacha: bad key length")
-	errInvalidNonce = errors.New("chacha20/chacha: bad nonce length")
+	// ErrKeySize is returned if the key is not 256 bits long.
+	ErrKeySize = errors.New("chacha20/chacha: bad key length")
+
+	// ErrNonceSize is returned if the nonce length doesn't match
+	// any (supported) ChaCha version.
+	ErrNonceSize = errors.New("chacha20/chacha: bad nonce length")
+
+	// ErrRounds is returned if the number of rounds is neither 8, 12 nor 20.
+	ErrRounds = errors.New("chacha20/chacha: bad number of rounds")
+
+	// ErrCounterOverflow is returned if en/decrypting a message would
+	// exceed the keystream period of the ChaCha version.
+	ErrCounterOverflow = errors.New("chacha20/chacha: counter overflow")
+
+	// ErrShortBuffer is returned if the dst buffer is smaller than src.
+	ErrShortBuffer = errors.New("chacha20/chacha: dst buffer is to small")
+
+	// ErrWiped is returned if a Cipher is used after Wipe or Close.
+	ErrWiped = errors.New("chacha20/chacha: cipher has been wiped")
+
+	// ErrOverlap is returned if dst and src overlap but are not the same slice.
+	ErrOverlap = errors.New("chacha20/chacha: invalid buffer overlap")
+
+	// ErrOffset is returned if a Reader offset is negative.
+	ErrOffset = errors.New("chacha20/chacha: invalid offset")
 )
 
-func setup(state *[64]byte, nonce, key []byte) (err error) {
+// setup initializes the state. For XNonceSize the sub-key is derived
+// using HChaCha with hRounds rounds.
+func setup(state *[64]byte, nonce, key []byte, hRounds int) (err error) {
 	if len(key) != KeySize {
-		err = errKeySize
+		err = ErrKeySize
 		return
 	}
 	var Nonce [16]byte
@@ -52,23 +77,19 @@ func setup(state *[64]byte, nonce, key []byte) (err error) {
 		copy(Nonce[4:], nonce)
 		initialize(state, key, &Nonce)
 	case XNonceSize:
+		// The 64 bit counter occupies the 4 zero bytes of the
+		// IETF nonce - so both XChaCha versions share this layout.
 		var tmpKey [32]byte
 		var hNonce [16]byte
 
 		copy(hNonce[:], nonce[:16])
 		copy(tmpKey[:], key)
-		HChaCha20(&tmpKey, &hNonce, &tmpKey)
+		hChaCha(&tmpKey, &hNonce, &tmpKey, hRounds)
 		copy(Nonce[8:], nonce[16:])
 		initialize(state, tmpKey[:], &Nonce)
-
-		// BUG(aead): A "good" compiler will remove this (optimizations)
-		//			  But using the provided key instead of tmpKey,
-		//			  will change the key (-> probably confuses users)
-		for i := range tmpKey {
-			tmpKey[i] = 0
-		}
+		wipe(tmpKey[:])
 	default:
-		err = errInvalidNonce
+		err = ErrNonceSize
 	}
 	return
 }
@@ -80,34 +101,123 @@ func setup(state *[64]byte, nonce, key []byte) (err error) {
 // - XNonceSize: XChaCha20/r with a 192 bit nonce and a 2^64 * 64 byte period.
 // The rounds argument specifies the number of rounds performed for keystream
 // generation - valid values are 8, 12 or 20. The src and dst may be the same slice
-// but otherwise should not overlap. If len(dst) < len(src) this function panics.
+// but otherwise must not overlap. If len(dst) < len(src) or dst and src overlap
+// inexactly this function panics.
 // If the nonce is neither 64, 96 nor 192 bits long, this function panics.
+// Use XORKeyStreamChecked to handle invalid arguments without a panic.
 func XORKeyStream(dst, src, nonce, key []byte, rounds int) {
+	if err := XORKeyStreamChecked(dst, src, nonce, key, rounds); err != nil {
+		panic(err)
+	}
+}
+
+// XORKeyStreamChecked behaves like XORKeyStream but returns ErrRounds, ErrShortBuffer,
+// ErrOverlap, ErrCounterOverflow, ErrKeySize or ErrNonceSize instead of panicking on
+// invalid arguments. If a non-nil error is returned dst is not modified.
+func XORKeyStreamChecked(dst, src, nonce, key []byte, rounds int) error {
+	return xorKeyStreamAt(dst, src, nonce, key, rounds, 0)
+}
+
+// XORKeyStreamAt behaves like XORKeyStream but starts at the 64 byte block
+// counter instead of block 0 - e.g. RFC 8439 encrypts the plaintext starting
+// at block 1. If the counter doesn't fit into the 32 bit counter of the IETF
+// version or en/decrypting src would exceed the keystream period, this function
+// panics.
+func XORKeyStreamAt(dst, src, nonce, key []byte, rounds int, counter uint64) {
+	if err := xorKeyStreamAt(dst, src, nonce, key, rounds, counter); err != nil {
+		panic(err)
+	}
+}
+
+func xorKeyStreamAt(dst, src, nonce, key []byte, rounds int, counter uint64) error {
+	if err := checkXORKeyStream(dst, src, nonce, rounds, counter); err != nil {
+		return err
+	}
+
+	var block, state [64]byte
+	if err := setup(&state, nonce, key, 20); err != nil {
+		return err
+	}
+	setCounter(&state, counter, len(nonce))
+	xorKeyStream(dst, src, &block, &state, rounds)
+	return nil
+}
+
+// checkXORKeyStream returns a non-nil error if en/decrypting src to dst
+// starting at the block counter is not possible.
+func checkXORKeyStream(dst, src, nonce []byte, rounds int, counter uint64) error {
 	if rounds != 20 && rounds != 12 && rounds != 8 {
-		panic("chacha20/chacha: bad number of rounds")
+		return ErrRounds
 	}
 	if len(dst) < len(src) {
-		panic("chacha20/chacha: dst buffer is to small")
+		return ErrShortBuffer
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		return ErrOverlap
+	}
+
+	blocks := uint64(len(src)+63) / 64
+	if len(nonce) == INonceSize {
+		if counter > math.MaxUint32 || blocks > (1<<32)-counter {
+			return ErrCounterOverflow
+		}
+	} else if blocks > 0 && counter > math.MaxUint64-(blocks-1) {
+		return ErrCounterOverflow
+	}
+	return nil
+}
+
+// setCounter writes the block counter to the state. For INonceSize
+// the counter is 32 bits wide - otherwise it is 64 bits wide.
+func setCounter(state *[64]byte, counter uint64, noncesize int) {
+	if noncesize == INonceSize {
+		binary.LittleEndian.PutUint32(state[48:], uint32(counter))
+	} else {
+		binary.LittleEndian.PutUint64(state[48:], counter)
+	}
+}
+
+// KeyStream writes the keystream for the given nonce and key to dst. It is
+// equivalent to XORKeyStream with an all-zero src of len(dst) bytes.
+// The rounds argument specifies the number of rounds performed for keystream
+// generation - valid values are 8, 12 or 20.
+// If the nonce is neither 64, 96 nor 192 bits long, this function panics.
+func KeyStream(dst, nonce, key []byte, rounds int) {
+	if rounds != 20 && rounds != 12 && rounds != 8 {
+		panic(ErrRounds)
 	}
-	if len(nonce) == INonceSize && uint64(len(src)) > (1<<38) {
-		panic("chacha20/chacha: src is too large")
+	if len(nonce) == INonceSize && uint64(len(dst)) > (1<<38) {
+		panic(ErrCounterOverflow)
 	}
 
 	var block, state [64]byte
-	if err := setup(&state, nonce, key); err != nil {
+	if err := setup(&state, nonce, key, 20); err != nil {
 		panic(err)
 	}
-	xorKeyStream(dst, src, &block, &state, rounds)
+	keyStream(dst, &block, &state, rounds)
 }
 
 // Cipher implements ChaCha20/r (XChaCha20/r) for a given number of rounds r.
 type Cipher struct {
 	state, block [64]byte
 	off          int
-	rounds       int // 20 for ChaCha20
+	rounds       int // 20 for ChaCha20 - 0 if the cipher has been wiped
 	noncesize    int
+	variant      int // the constructor of the cipher - used by Reset
+
+	hNonce  [16]byte // the HChaCha nonce of XChaCha ciphers
+	hRounds int      // the HChaCha rounds of XChaCha ciphers - 0 otherwise
+
+	exhausted bool // true if the last block of the keystream period has been generated
 }
 
+// The ChaCha variants created by NewCipher, NewCipherIETF and NewXCipher.
+const (
+	variantChaCha = iota
+	variantIETF
+	variantX
+)
+
 // NewCipher returns a new *chacha.Cipher implementing the ChaCha20/r or XChaCha20/r
 // (r = 8, 12 or 20) stream cipher. The nonce must be unique for one key for all time.
 // The length of the nonce determinds the version of ChaCha20:
@@ -115,31 +225,156 @@ type Cipher struct {
 // - INonceSize: ChaCha20/r as defined in RFC 7539 and a 2^32 * 64 byte period.
 // - XNonceSize: XChaCha20/r with a 192 bit nonce and a 2^64 * 64 byte period.
 // If the nonce is neither 64, 96 nor 192 bits long, a non-nil error is returned.
+// If the number of rounds is invalid, ErrRounds is returned.
+//
+// For XNonceSize the counter is 64 bits wide. Therefore the keystream differs from
+// XChaCha20 as specified in draft-irtf-cfrg-xchacha (and libsodium's IETF variant)
+// once the counter exceeds 2^32 - 1. Use NewCipherIETF for the IETF version.
 func NewCipher(nonce, key []byte, rounds int) (*Cipher, error) {
-	if rounds != 20 && rounds != 12 && rounds != 8 {
-		panic("chacha20/chacha: bad number of rounds")
+	c := new(Cipher)
+	if err := c.reset(nonce, key, rounds, variantChaCha); err != nil {
+		return nil, err
 	}
+	return c, nil
+}
 
+// NewCipherIETF returns a new *chacha.Cipher implementing the IETF versions of
+// ChaCha20/r or XChaCha20/r (r = 8, 12 or 20). Both use a 32 bit counter and
+// can en/decrypt up to 2^32 * 64 bytes for one key-nonce combination.
+// The nonce must be unique for one key for all time.
+// The length of the nonce determinds the version of ChaCha20:
+// - INonceSize: ChaCha20/r as defined in RFC 7539.
+// - XNonceSize: XChaCha20/r as defined in draft-irtf-cfrg-xchacha.
+// For XNonceSize the sub-key is derived from the first 16 bytes of the nonce
+// using HChaCha20 and the remaining 8 bytes are prefixed with 4 zero bytes.
+// If the nonce is neither 96 nor 192 bits long, a non-nil error is returned.
+func NewCipherIETF(nonce, key []byte, rounds int) (*Cipher, error) {
 	c := new(Cipher)
-	if err := setup(&(c.state), nonce, key); err != nil {
+	if err := c.reset(nonce, key, rounds, variantIETF); err != nil {
 		return nil, err
 	}
-	c.rounds = rounds
+	return c, nil
+}
 
-	if len(nonce) == INonceSize {
-		c.noncesize = INonceSize
-	} else {
-		c.noncesize = NonceSize
+// NewXCipher returns a new *chacha.Cipher implementing XChaCha20/r
+// (r = 8, 12 or 20) with a 192 bit nonce and a 2^64 * 64 byte period.
+// In contrast to NewCipher the sub-key is derived using HChaCha/r instead
+// of HChaCha20 - so XChaCha12 matches the construction used by Adiantum
+// and the Linux kernel. For r = 20 both functions are equivalent.
+// If the nonce is not 192 bits long, a non-nil error is returned.
+func NewXCipher(nonce, key []byte, rounds int) (*Cipher, error) {
+	c := new(Cipher)
+	if err := c.reset(nonce, key, rounds, variantX); err != nil {
+		return nil, err
 	}
+	return c, nil
+}
 
+// NewCipherWithCounter behaves like NewCipher but the keystream starts at the
+// 64 byte block counter instead of block 0. It is equivalent to NewCipher
+// followed by SetCounter(counter). If the counter doesn't fit into the 32 bit
+// counter of the IETF version, ErrCounterOverflow is returned.
+func NewCipherWithCounter(nonce, key []byte, rounds int, counter uint64) (*Cipher, error) {
+	c, err := NewCipher(nonce, key, rounds)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.SetCounterChecked(counter); err != nil {
+		c.Wipe()
+		return nil, err
+	}
 	return c, nil
 }
 
+// Reset re-initializes the cipher with the given nonce and key without
+// allocating memory. The cipher keeps its number of rounds and accepts
+// the same nonces as the constructor that created it - e.g. a cipher
+// returned by NewCipherIETF remains an IETF cipher.
+// Reset returns ErrWiped if the cipher has been wiped and ErrKeySize or
+// ErrNonceSize if the key or nonce is invalid. If a non-nil error is
+// returned the cipher is not modified.
+func (c *Cipher) Reset(nonce, key []byte) error {
+	if c.rounds == 0 {
+		return ErrWiped
+	}
+	return c.reset(nonce, key, c.rounds, c.variant)
+}
+
+// Clone returns an independent copy of the cipher. The copy continues
+// at the current keystream position of c. Both ciphers produce the
+// same keystream - so the copy must not be used to encrypt different
+// messages than c.
+func (c *Cipher) Clone() *Cipher {
+	clone := *c
+	return &clone
+}
+
+// reset initializes the cipher as the constructor of the given variant.
+func (c *Cipher) reset(nonce, key []byte, rounds, variant int) error {
+	if rounds != 20 && rounds != 12 && rounds != 8 {
+		return ErrRounds
+	}
+	hRounds, noncesize := 20, NonceSize
+	switch variant {
+	case variantIETF:
+		if len(nonce) != INonceSize && len(nonce) != XNonceSize {
+			return ErrNonceSize
+		}
+		noncesize = INonceSize
+	case variantX:
+		if len(nonce) != XNonceSize {
+			return ErrNonceSize
+		}
+		hRounds = rounds
+	default:
+		if len(nonce) == INonceSize {
+			noncesize = INonceSize
+		}
+	}
+
+	if err := setup(&(c.state), nonce, key, hRounds); err != nil {
+		return err // setup doesn't modify the state on errors
+	}
+	wipe(c.block[:])
+	c.off = 0
+	c.exhausted = false
+	c.rounds = rounds
+	c.noncesize = noncesize
+	c.variant = variant
+
+	c.hNonce, c.hRounds = [16]byte{}, 0
+	if len(nonce) == XNonceSize {
+		copy(c.hNonce[:], nonce[:16])
+		c.hRounds = hRounds
+	}
+	return nil
+}
+
 // XORKeyStream crypts bytes from src to dst. Src and dst may be the same slice
-// but otherwise should not overlap. If len(dst) < len(src) the function panics.
+// but otherwise must not overlap. If len(dst) < len(src) or dst and src overlap
+// inexactly the function panics. If en/decrypting src would exceed the keystream
+// period or the cipher has been wiped the function panics.
 func (c *Cipher) XORKeyStream(dst, src []byte) {
+	if err := c.XORKeyStreamChecked(dst, src); err != nil {
+		panic(err)
+	}
+}
+
+// XORKeyStreamChecked behaves like XORKeyStream but returns ErrShortBuffer, ErrOverlap,
+// ErrCounterOverflow or ErrWiped instead of panicking. If a non-nil error is returned
+// neither dst nor the cipher state is modified.
+func (c *Cipher) XORKeyStreamChecked(dst, src []byte) error {
+	if c.rounds == 0 {
+		return ErrWiped
+	}
 	if len(dst) < len(src) {
-		panic("chacha20/chacha: dst buffer is to small")
+		return ErrShortBuffer
+	}
+	if inexactOverlap(dst[:len(src)], src) {
+		return ErrOverlap
+	}
+	if c.overflows(len(src)) {
+		return ErrCounterOverflow
 	}
 
 	if c.off > 0 {
@@ -152,7 +387,7 @@ func (c *Cipher) XORKeyStream(dst, src []byte) {
 			if c.off == 64 {
 				c.off = 0
 			}
-			return
+			return nil
 		}
 
 		for i, v := range c.block[c.off:] {
@@ -163,35 +398,187 @@ func (c *Cipher) XORKeyStream(dst, src []byte) {
 		c.off = 0
 	}
 
-	// check for counter overflow
-	blocksToXOR := len(src) / 64
-	if len(src)%64 != 0 {
-		blocksToXOR++
+	if len(src) > 0 {
+		c.off += xorKeyStream(dst, src, &(c.block), &(c.state), c.rounds)
+		c.checkExhausted()
 	}
-	var overflow bool
-	if c.noncesize == INonceSize {
-		overflow = binary.LittleEndian.Uint32(c.state[48:]) > math.MaxUint32-uint32(blocksToXOR)
-	} else {
-		overflow = binary.LittleEndian.Uint64(c.state[48:]) > math.MaxUint64-uint64(blocksToXOR)
+	return nil
+}
+
+// KeyStream writes the next len(dst) bytes of the keystream to dst. It is
+// equivalent to XORKeyStream with an all-zero src but doesn't read src.
+// If writing len(dst) bytes would exceed the keystream period or the cipher
+// has been wiped the function panics.
+func (c *Cipher) KeyStream(dst []byte) {
+	if c.rounds == 0 {
+		panic(ErrWiped)
+	}
+	if c.overflows(len(dst)) {
+		panic(ErrCounterOverflow)
+	}
+
+	if c.off > 0 {
+		n := copy(dst, c.block[c.off:])
+		dst = dst[n:]
+		c.off += n
+		if c.off == 64 {
+			c.off = 0
+		}
 	}
-	if overflow {
-		panic("chacha20/chacha: counter overflow")
+	if len(dst) > 0 {
+		c.off += keyStream(dst, &(c.block), &(c.state), c.rounds)
+		c.checkExhausted()
 	}
+}
 
-	c.off += xorKeyStream(dst, src, &(c.block), &(c.state), c.rounds)
+// overflows returns true if en/decrypting n more bytes
+// exceeds the keystream period of the cipher. As for
+// checkXORKeyStream the block at the max. counter value
+// is part of the period.
+func (c *Cipher) overflows(n int) bool {
+	if c.off > 0 {
+		n -= 64 - c.off // the remaining keystream of the current block
+	}
+	if n <= 0 {
+		return false
+	}
+	if c.exhausted {
+		return true
+	}
+
+	blocksToXOR := uint64(n+63) / 64
+	if c.noncesize == INonceSize {
+		return blocksToXOR > (1<<32)-uint64(binary.LittleEndian.Uint32(c.state[48:]))
+	}
+	return binary.LittleEndian.Uint64(c.state[48:]) > math.MaxUint64-(blocksToXOR-1)
+}
+
+// checkExhausted must be called after generating at least one keystream
+// block. If the counter has wrapped to 0 the last block of the keystream
+// period has been generated and the cipher is exhausted. The 32 bit counter
+// of the IETF version carries into the nonce - so the carry is removed again.
+func (c *Cipher) checkExhausted() {
+	if c.noncesize == INonceSize {
+		if binary.LittleEndian.Uint32(c.state[48:]) == 0 {
+			binary.LittleEndian.PutUint32(c.state[52:], binary.LittleEndian.Uint32(c.state[52:])-1)
+			c.exhausted = true
+		}
+	} else if binary.LittleEndian.Uint64(c.state[48:]) == 0 {
+		c.exhausted = true
+	}
 }
 
 // SetCounter skips ctr * 64 byte blocks. SetCounter(0) resets the cipher.
 // This function always skips the unused keystream of the current 64 byte block.
+// If ctr doesn't fit into the 32 bit counter of an IETF cipher this function
+// panics.
 func (c *Cipher) SetCounter(ctr uint64) {
+	if err := c.SetCounterChecked(ctr); err != nil {
+		panic(err)
+	}
+}
+
+// SetCounterChecked behaves like SetCounter but returns ErrCounterOverflow
+// instead of panicking if ctr doesn't fit into the 32 bit counter of an IETF
+// cipher. If a non-nil error is returned the cipher state is not modified.
+func (c *Cipher) SetCounterChecked(ctr uint64) error {
+	if c.noncesize == INonceSize && ctr > math.MaxUint32 {
+		return ErrCounterOverflow
+	}
+	setCounter(&(c.state), ctr, c.noncesize)
+	c.off = 0
+	c.exhausted = false
+	return nil
+}
+
+// Counter returns the number of the 64 byte block containing the
+// next keystream byte. After SetCounter(ctr) Counter returns ctr.
+func (c *Cipher) Counter() uint64 {
+	var ctr uint64
 	if c.noncesize == INonceSize {
-		binary.LittleEndian.PutUint32(c.state[48:], uint32(ctr))
+		ctr = uint64(binary.LittleEndian.Uint32(c.state[48:]))
+		if c.exhausted {
+			ctr = 1 << 32 // the counter has wrapped
+		}
 	} else {
-		binary.LittleEndian.PutUint64(c.state[48:], ctr)
+		ctr = binary.LittleEndian.Uint64(c.state[48:])
+	}
+	if c.off > 0 {
+		ctr-- // the current block is already generated
+	}
+	return ctr
+}
+
+// Seek moves the keystream to the given byte offset. In contrast to SetCounter
+// the offset doesn't have to be a multiple of 64. The keystream of a partial
+// block is generated immediately, so the next call of XORKeyStream continues
+// in the middle of that block. For ciphers with a 32 bit counter Seek returns
+// ErrCounterOverflow if the offset exceeds the keystream period.
+func (c *Cipher) Seek(offset uint64) error {
+	if c.rounds == 0 {
+		return ErrWiped
+	}
+	if c.noncesize == INonceSize && offset >= (1<<32)*64 {
+		return ErrCounterOverflow
 	}
+
+	c.SetCounter(offset / 64)
+	if n := int(offset % 64); n > 0 {
+		var block [64]byte
+		keyStream(block[:], &(c.block), &(c.state), c.rounds)
+		c.checkExhausted()
+		c.block = block
+		c.off = n
+	}
+	return nil
+}
+
+// Wipe overwrites the key material and the buffered keystream of the cipher
+// with zeros. After Wipe the cipher cannot be used anymore - XORKeyStream
+// panics and XORKeyStreamChecked and Seek return ErrWiped.
+func (c *Cipher) Wipe() {
+	wipe(c.state[:])
+	wipe(c.block[:])
 	c.off = 0
+	c.exhausted = false
+	c.rounds = 0
+}
+
+// Close wipes the cipher as described by Wipe. It always returns nil.
+func (c *Cipher) Close() error {
+	c.Wipe()
+	return nil
+}
+
+// wipe overwrites b with zeros. It must not be inlined,
+// such that the compiler cannot remove the stores as dead code.
+//
+//go:noinline
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// inexactOverlap reports whether x and y share memory at any non-corresponding
+// index. x and y may be the same slice - e.g. for in-place en/decryption.
+func inexactOverlap(x, y []byte) bool {
+	if len(x) == 0 || len(y) == 0 || &x[0] == &y[0] {
+		return false
+	}
+	return anyOverlap(x, y)
 }
 
 // HChaCha20 generates 32 pseudo-random bytes from a 128 bit nonce and a 256 bit secret key.
 // It can be used as a key-derivation-function (KDF).
-func HChaCha20(out *[32]byte, nonce *[16]byte, key *[32]byte) { hChaCha20(out, nonce, key) }
+func HChaCha20(out *[32]byte, nonce *[16]byte, key *[32]byte) { hChaCha(out, nonce, key, 20) }
+
+// HChaCha generates 32 pseudo-random bytes from a 128 bit nonce and a 256 bit secret key
+// using HChaCha/r. The rounds argument must be 8, 12 or 20 - otherwise this function panics.
+// HChaCha(out, nonce, key, 20) is equivalent to HChaCha20(out, nonce, key).
+func HChaCha(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int) {
+	if rounds != 20 && rounds != 12 && rounds != 8 {
+		panic(ErrRounds)
+	}
+	hChaCha(out, nonce, key, rounds)
+}
@@ -30,24 +30,28 @@ func initialize(state *[64]byte, key []byte, nonce *[16]byte) {
 
 // This function is implemented in chacha_386.s
 //go:noescape
-func hChaCha20SSE2(out *[32]byte, nonce *[16]byte, key *[32]byte)
+func hChaChaSSE2(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int)
 
 // This function is implemented in chacha_386.s
 //go:noescape
-func hChaCha20SSSE3(out *[32]byte, nonce *[16]byte, key *[32]byte)
+func hChaChaSSSE3(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int)
 
 // This function is implemented in chacha_386.s
 //go:noescape
 func xorKeyStreamSSE2(dst, src []byte, block, state *[64]byte, rounds int) int
 
-func hChaCha20(out *[32]byte, nonce *[16]byte, key *[32]byte) {
+// This function is implemented in chacha_386.s
+//go:noescape
+func keyStreamSSE2(dst []byte, block, state *[64]byte, rounds int) int
+
+func hChaCha(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int) {
 	switch {
 	case useSSSE3:
-		hChaCha20SSSE3(out, nonce, key)
+		hChaChaSSSE3(out, nonce, key, rounds)
 	case useSSE2:
-		hChaCha20SSE2(out, nonce, key)
+		hChaChaSSE2(out, nonce, key, rounds)
 	default:
-		hChaCha20Generic(out, nonce, key)
+		hChaChaGeneric(out, nonce, key, rounds)
 	}
 }
 
@@ -58,3 +62,14 @@ func xorKeyStream(dst, src []byte, block, state *[64]byte, rounds int) int {
 		return xorKeyStreamGeneric(dst, src, block, state, rounds)
 	}
 }
+
+func keyStream(dst []byte, block, state *[64]byte, rounds int) int {
+	if useSSE2 {
+		return keyStreamSSE2(dst, block, state, rounds)
+	}
+	return keyStreamGeneric(dst, block, state, rounds)
+}
+
+func blocks(out *[8 * 64]byte, states *[8][64]byte, n, rounds int) {
+	blocksGeneric(out, states, n, rounds)
+}
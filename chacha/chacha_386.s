@@ -24,13 +24,25 @@
 	DECL len;          \
 	JG   FINALIZE_LOOP \
 
+// COPY_KEYSTREAM copies len bytes from block to dst
+// using the temp. register t.
+#define COPY_KEYSTREAM(dst, block, len, t) \
+	XORL t, t;        \
+	COPY_LOOP:;       \
+	MOVB 0(block), t; \
+	MOVB t, 0(dst);   \
+	INCL block;       \
+	INCL dst;         \
+	DECL len;         \
+	JG   COPY_LOOP    \
+
 #define Dst DI
 #define Nonce AX
 #define Key BX
 #define Rounds DX
 
-// func hChaCha20SSE2(out *[32]byte, nonce *[16]byte, key *[32]byte)
-TEXT ·hChaCha20SSE2(SB), 4, $0-12
+// func hChaChaSSE2(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int)
+TEXT ·hChaChaSSE2(SB), 4, $0-16
 	MOVL out+0(FP), Dst
 	MOVL nonce+4(FP), Nonce
 	MOVL key+8(FP), Key
@@ -39,7 +51,7 @@ TEXT ·hChaCha20SSE2(SB), 4, $0-12
 	MOVOU 0*16(Key), X1
 	MOVOU 1*16(Key), X2
 	MOVOU 0*16(Nonce), X3
-	MOVL  $20, Rounds
+	MOVL  rounds+12(FP), Rounds
 
 chacha_loop:
 	CHACHA_QROUND_SSE2(X0, X1, X2, X3, X4)
@@ -53,8 +65,8 @@ chacha_loop:
 	MOVOU X3, 1*16(Dst)
 	RET
 
-// func hChaCha20SSSE3(out *[32]byte, nonce *[16]byte, key *[32]byte)
-TEXT ·hChaCha20SSSE3(SB), 4, $0-12
+// func hChaChaSSSE3(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int)
+TEXT ·hChaChaSSSE3(SB), 4, $0-16
 	MOVL out+0(FP), Dst
 	MOVL nonce+4(FP), Nonce
 	MOVL key+8(FP), Key
@@ -63,7 +75,7 @@ TEXT ·hChaCha20SSSE3(SB), 4, $0-12
 	MOVOU 0*16(Key), X1
 	MOVOU 1*16(Key), X2
 	MOVOU 0*16(Nonce), X3
-	MOVL  $20, Rounds
+	MOVL  rounds+12(FP), Rounds
 
 	MOVOU ·rol16<>(SB), X5
 	MOVOU ·rol8<>(SB), X6
@@ -155,6 +167,67 @@ DONE:
 	MOVOU X3, 3*16(State)
 	RET
 
+// func keyStreamSSE2(dst []byte, block, state *[64]byte, rounds int) int
+TEXT ·keyStreamSSE2(SB), 4, $0-28
+	MOVL dst_base+0(FP), Dst
+	MOVL state+16(FP), State
+	MOVL dst_len+4(FP), Len
+	MOVL $0, ret+24(FP)       // Number of bytes written to the keystream buffer - 0 iff len mod 64 == 0
+
+	MOVOU 0*16(State), X0
+	MOVOU 1*16(State), X1
+	MOVOU 2*16(State), X2
+	MOVOU 3*16(State), X3
+	TESTL Len, Len
+	JZ    DONE
+
+GENERATE_KEYSTREAM:
+	MOVO X0, X4
+	MOVO X1, X5
+	MOVO X2, X6
+	MOVO X3, X7
+	MOVL rounds+20(FP), Tmp0
+
+CHACHA_LOOP:
+	CHACHA_QROUND_SSE2(X4, X5, X6, X7, X0)
+	CHACHA_SHUFFLE_SSE(X5, X6, X7)
+	CHACHA_QROUND_SSE2(X4, X5, X6, X7, X0)
+	CHACHA_SHUFFLE_SSE(X7, X6, X5)
+	SUBL $2, Tmp0
+	JA   CHACHA_LOOP
+
+	MOVOU 0*16(State), X0 // Restore X0 from state
+	PADDL X0, X4
+	PADDL X1, X5
+	PADDL X2, X6
+	PADDL X3, X7
+	MOVOU ·one<>(SB), X0
+	PADDQ X0, X3
+
+	CMPL Len, $64
+	JL   BUFFER_KEYSTREAM
+
+	STORE_SSE(Dst, 0, X4, X5, X6, X7)
+	MOVOU 0*16(State), X0    // Restore X0 from state
+	ADDL  $64, Dst
+	SUBL  $64, Len
+	JZ    DONE
+	JMP   GENERATE_KEYSTREAM // There is at least one more plaintext byte
+
+BUFFER_KEYSTREAM:
+	MOVL  block+12(FP), State
+	MOVOU X4, 0(State)
+	MOVOU X5, 16(State)
+	MOVOU X6, 32(State)
+	MOVOU X7, 48(State)
+	MOVL  Len, ret+24(FP)     // Number of bytes written to the keystream buffer - 0 < Len < 64
+	COPY_KEYSTREAM(Dst, State, Len, Tmp0)
+
+DONE:
+	MOVL  state+16(FP), State
+	MOVOU X3, 3*16(State)
+	RET
+
 #undef State
 #undef Dst
 #undef Src
@@ -21,15 +21,15 @@ func initialize(state *[64]byte, key []byte, nonce *[16]byte)
 
 // This function is implemented in chacha_amd64.s
 //go:noescape
-func hChaCha20SSE2(out *[32]byte, nonce *[16]byte, key *[32]byte)
+func hChaChaSSE2(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int)
 
 // This function is implemented in chacha_amd64.s
 //go:noescape
-func hChaCha20SSSE3(out *[32]byte, nonce *[16]byte, key *[32]byte)
+func hChaChaSSSE3(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int)
 
-// This function is implemented in chachaAVX2_amd64.s
+// This function is implemented in chacha_amd64.s
 //go:noescape
-func hChaCha20AVX(out *[32]byte, nonce *[16]byte, key *[32]byte)
+func hChaChaAVX(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int)
 
 // This function is implemented in chacha_amd64.s
 //go:noescape
@@ -47,16 +47,40 @@ func xorKeyStreamAVX(dst, src []byte, block, state *[64]byte, rounds int) int
 //go:noescape
 func xorKeyStreamAVX2(dst, src []byte, block, state *[64]byte, rounds int) int
 
-func hChaCha20(out *[32]byte, nonce *[16]byte, key *[32]byte) {
+// This function is implemented in chacha_amd64.s
+//go:noescape
+func keyStreamSSE2(dst []byte, block, state *[64]byte, rounds int) int
+
+// This function is implemented in chacha_amd64.s
+//go:noescape
+func keyStreamSSSE3(dst []byte, block, state *[64]byte, rounds int) int
+
+// This function is implemented in chacha_amd64.s
+//go:noescape
+func keyStreamAVX(dst []byte, block, state *[64]byte, rounds int) int
+
+// This function is implemented in chachaAVX2_amd64.s
+//go:noescape
+func keyStreamAVX2(dst []byte, block, state *[64]byte, rounds int) int
+
+// This function is implemented in batch_amd64.s
+//go:noescape
+func blocksSSSE3(out *byte, states *[64]byte, rounds int)
+
+// This function is implemented in batch_amd64.s
+//go:noescape
+func blocksAVX2(out *byte, states *[64]byte, rounds int)
+
+func hChaCha(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int) {
 	switch {
 	case useAVX:
-		hChaCha20AVX(out, nonce, key)
+		hChaChaAVX(out, nonce, key, rounds)
 	case useSSSE3:
-		hChaCha20SSSE3(out, nonce, key)
+		hChaChaSSSE3(out, nonce, key, rounds)
 	case useSSE2:
-		hChaCha20SSE2(out, nonce, key)
+		hChaChaSSE2(out, nonce, key, rounds)
 	default:
-		hChaCha20Generic(out, nonce, key)
+		hChaChaGeneric(out, nonce, key, rounds)
 	}
 }
 
@@ -74,3 +98,32 @@ func xorKeyStream(dst, src []byte, block, state *[64]byte, rounds int) int {
 		return xorKeyStreamGeneric(dst, src, block, state, rounds)
 	}
 }
+
+func keyStream(dst []byte, block, state *[64]byte, rounds int) int {
+	switch {
+	case useAVX2:
+		return keyStreamAVX2(dst, block, state, rounds)
+	case useAVX:
+		return keyStreamAVX(dst, block, state, rounds)
+	case useSSSE3:
+		return keyStreamSSSE3(dst, block, state, rounds)
+	case useSSE2:
+		return keyStreamSSE2(dst, block, state, rounds)
+	default:
+		return keyStreamGeneric(dst, block, state, rounds)
+	}
+}
+
+func blocks(out *[8 * 64]byte, states *[8][64]byte, n, rounds int) {
+	switch {
+	case useAVX2:
+		blocksAVX2(&out[0], &states[0], rounds)
+	case useSSSE3:
+		blocksSSSE3(&out[0], &states[0], rounds)
+		if n > 4 {
+			blocksSSSE3(&out[4*64], &states[4], rounds)
+		}
+	default:
+		blocksGeneric(out, states, n, rounds)
+	}
+}
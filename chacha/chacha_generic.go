@@ -29,6 +29,21 @@ func xorKeyStreamGeneric(dst, src []byte, block, state *[64]byte, rounds int) in
 	return n
 }
 
+func keyStreamGeneric(dst []byte, block, state *[64]byte, rounds int) int {
+	for len(dst) >= 64 {
+		chachaGeneric(block, state, rounds)
+		copy(dst, block[:])
+		dst = dst[64:]
+	}
+
+	n := len(dst)
+	if n > 0 {
+		chachaGeneric(block, state, rounds)
+		copy(dst, block[:])
+	}
+	return n
+}
+
 func chachaGeneric(dst *[64]byte, state *[64]byte, rounds int) {
 	v00 := binary.LittleEndian.Uint32(state[0:])
 	v01 := binary.LittleEndian.Uint32(state[4:])
@@ -191,7 +206,7 @@ func chachaGeneric(dst *[64]byte, state *[64]byte, rounds int) {
 	binary.LittleEndian.PutUint32(dst[60:], v15)
 }
 
-func hChaCha20Generic(out *[32]byte, nonce *[16]byte, key *[32]byte) {
+func hChaChaGeneric(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int) {
 	v00 := sigma[0]
 	v01 := sigma[1]
 	v02 := sigma[2]
@@ -209,7 +224,7 @@ func hChaCha20Generic(out *[32]byte, nonce *[16]byte, key *[32]byte) {
 	v14 := binary.LittleEndian.Uint32(nonce[8:])
 	v15 := binary.LittleEndian.Uint32(nonce[12:])
 
-	for i := 0; i < 20; i += 2 {
+	for i := 0; i < rounds; i += 2 {
 		v00 += v04
 		v12 ^= v00
 		v12 = (v12 << 16) | (v12 >> 16)
@@ -317,3 +332,14 @@ func hChaCha20Generic(out *[32]byte, nonce *[16]byte, key *[32]byte) {
 	binary.LittleEndian.PutUint32(out[24:], v14)
 	binary.LittleEndian.PutUint32(out[28:], v15)
 }
+
+func blocksGeneric(out *[8 * 64]byte, states *[8][64]byte, n, rounds int) {
+	var s, block [64]byte
+	for i := 0; i < n; i++ {
+		s = states[i] // chachaGeneric increments the counter
+		chachaGeneric(&block, &s, rounds)
+		copy(out[i*64:], block[:])
+	}
+	wipe(s[:])
+	wipe(block[:])
+}
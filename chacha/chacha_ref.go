@@ -28,6 +28,14 @@ func xorKeyStream(dst, src []byte, block, state *[64]byte, rounds int) int {
 	return xorKeyStreamGeneric(dst, src, block, state, rounds)
 }
 
-func hChaCha20(out *[32]byte, nonce *[16]byte, key *[32]byte) {
-	hChaCha20Generic(out, nonce, key)
+func keyStream(dst []byte, block, state *[64]byte, rounds int) int {
+	return keyStreamGeneric(dst, block, state, rounds)
+}
+
+func hChaCha(out *[32]byte, nonce *[16]byte, key *[32]byte, rounds int) {
+	hChaChaGeneric(out, nonce, key, rounds)
+}
+
+func blocks(out *[8 * 64]byte, states *[8][64]byte, n, rounds int) {
+	blocksGeneric(out, states, n, rounds)
 }
@@ -7,7 +7,9 @@ package chacha
 import (
 	"bytes"
 	"crypto/cipher"
+	"encoding/binary"
 	"encoding/hex"
+	"math"
 	"testing"
 )
 
@@ -23,33 +25,42 @@ func fromHex(bits string) []byte {
 	return b
 }
 
-func TestHChaCha20(t *testing.T) {
+func TestHChaCha(t *testing.T) {
 	defer func(sse2, ssse3, avx, avx2 bool) {
 		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
 	}(useSSE2, useSSSE3, useAVX, useAVX2)
 
 	if useAVX2 {
 		t.Log("AVX2 version")
-		testHChaCha20(t)
+		testHChaCha(t)
 		useAVX2 = false
 	}
 	if useAVX {
 		t.Log("AVX version")
-		testHChaCha20(t)
+		testHChaCha(t)
 		useAVX = false
 	}
 	if useSSSE3 {
 		t.Log("SSSE3 version")
-		testHChaCha20(t)
+		testHChaCha(t)
 		useSSSE3 = false
 	}
 	if useSSE2 {
 		t.Log("SSE2 version")
-		testHChaCha20(t)
+		testHChaCha(t)
 		useSSE2 = false
 	}
 	t.Log("generic version")
-	testHChaCha20(t)
+	testHChaCha(t)
+
+	defer func() {
+		if err := recover(); err != ErrRounds {
+			t.Errorf("HChaCha panicked with %v - want %v", err, ErrRounds)
+		}
+	}()
+	var out, key [32]byte
+	var nonce [16]byte
+	HChaCha(&out, &nonce, &key, 10)
 }
 
 func TestVectors(t *testing.T) {
@@ -83,6 +94,7 @@ func TestVectors(t *testing.T) {
 
 var overflowTests = []struct {
 	NonceSize     int
+	IETF          bool
 	Counter       uint64
 	PlaintextSize int
 }{
@@ -92,18 +104,496 @@ var overflowTests = []struct {
 	{NonceSize: INonceSize, Counter: uint64(^uint32(1)), PlaintextSize: 129},
 	{NonceSize: XNonceSize, Counter: ^uint64(0), PlaintextSize: 65},
 	{NonceSize: XNonceSize, Counter: ^uint64(1), PlaintextSize: 129},
+	{NonceSize: INonceSize, IETF: true, Counter: uint64(^uint32(0)), PlaintextSize: 65},
+	{NonceSize: XNonceSize, IETF: true, Counter: uint64(^uint32(0)), PlaintextSize: 65},
+	{NonceSize: XNonceSize, IETF: true, Counter: uint64(^uint32(1)), PlaintextSize: 129},
 }
 
 func TestOverflow(t *testing.T) {
 	var key [32]byte
 	for i, test := range overflowTests {
-		stream, err := NewCipher(make([]byte, test.NonceSize), key[:], 20)
+		newCipher := NewCipher
+		if test.IETF {
+			newCipher = NewCipherIETF
+		}
+		stream, err := newCipher(make([]byte, test.NonceSize), key[:], 20)
 		if err != nil {
 			t.Errorf("Test %d: Failed to create cipher.Stream: %v", i, err)
 			continue
 		}
 		stream.SetCounter(test.Counter)
-		testOverflow(i, make([]byte, test.PlaintextSize), stream, t)
+		if ctr := stream.Counter(); ctr != test.Counter {
+			t.Errorf("Test %d: Counter returned %d - want %d", i, ctr, test.Counter)
+		}
+
+		plaintext := make([]byte, test.PlaintextSize)
+		if err = stream.XORKeyStreamChecked(plaintext, plaintext); err != ErrCounterOverflow {
+			t.Errorf("Test %d: XORKeyStreamChecked returned %v - want %v", i, err, ErrCounterOverflow)
+		}
+		for _, v := range plaintext {
+			if v != 0 {
+				t.Errorf("Test %d: XORKeyStreamChecked modified dst on error", i)
+				break
+			}
+		}
+		testOverflow(i, plaintext, stream, t)
+	}
+}
+
+var setCounterTests = []struct {
+	NonceSize int
+	IETF      bool
+	Counter   uint64
+	Overflow  bool
+}{
+	{NonceSize: NonceSize, Counter: 1 << 32},
+	{NonceSize: NonceSize, Counter: ^uint64(0)},
+	{NonceSize: INonceSize, Counter: uint64(^uint32(0))},
+	{NonceSize: INonceSize, Counter: 1 << 32, Overflow: true},
+	{NonceSize: INonceSize, Counter: (1 << 32) + 5, Overflow: true},
+	{NonceSize: INonceSize, Counter: ^uint64(0), Overflow: true},
+	{NonceSize: XNonceSize, Counter: (1 << 32) + 5},
+	{NonceSize: XNonceSize, IETF: true, Counter: uint64(^uint32(0))},
+	{NonceSize: XNonceSize, IETF: true, Counter: (1 << 32) + 5, Overflow: true},
+}
+
+func TestSetCounter(t *testing.T) {
+	var key [32]byte
+	for i, test := range setCounterTests {
+		newCipher := NewCipher
+		if test.IETF {
+			newCipher = NewCipherIETF
+		}
+		c, err := newCipher(make([]byte, test.NonceSize), key[:], 20)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create cipher: %v", i, err)
+		}
+		c.SetCounter(7)
+
+		err = c.SetCounterChecked(test.Counter)
+		if test.Overflow {
+			if err != ErrCounterOverflow {
+				t.Errorf("Test %d: SetCounterChecked returned %v - want %v", i, err, ErrCounterOverflow)
+			}
+			if ctr := c.Counter(); ctr != 7 {
+				t.Errorf("Test %d: SetCounterChecked modified the counter on error: got %d", i, ctr)
+			}
+			func() {
+				defer func() {
+					if err := recover(); err == nil {
+						t.Errorf("Test %d: expected SetCounter to panic but it succeeded", i)
+					}
+				}()
+				c.SetCounter(test.Counter)
+			}()
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: SetCounterChecked failed: %v", i, err)
+		}
+		if ctr := c.Counter(); ctr != test.Counter {
+			t.Errorf("Test %d: Counter returned %d - want %d", i, ctr, test.Counter)
+		}
+	}
+
+	c, err := NewCipher(make([]byte, INonceSize), key[:], 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 129)
+	for _, test := range []struct{ size, counter uint64 }{{0, 0}, {1, 0}, {62, 0}, {1, 1}, {64, 2}, {65, 3}} {
+		c.XORKeyStream(buf[:test.size], buf[:test.size])
+		if ctr := c.Counter(); ctr != test.counter {
+			t.Errorf("Counter returned %d after %d bytes - want %d", ctr, test.size, test.counter)
+		}
+	}
+	if err = c.Seek(130); err != nil {
+		t.Fatal(err)
+	}
+	if ctr := c.Counter(); ctr != 2 {
+		t.Errorf("Counter returned %d after Seek(130) - want %d", ctr, 2)
+	}
+}
+
+func TestXORKeyStreamAt(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	src := make([]byte, 300)
+	for i := range src {
+		src[i] = byte(i)
+	}
+
+	for _, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+		nonce := make([]byte, nonceSize)
+		for _, counter := range []uint64{0, 1, 7, (1 << 32) - 10} {
+			c, err := NewCipher(nonce, key, 20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SetCounter(counter)
+			want := make([]byte, len(src))
+			c.XORKeyStream(want, src)
+
+			dst := make([]byte, len(src))
+			XORKeyStreamAt(dst, src, nonce, key, 20, counter)
+			if !bytes.Equal(dst, want) {
+				t.Errorf("NonceSize %d: XORKeyStreamAt(%d) mismatch:\n \t got:  %s\n \t want: %s", nonceSize, counter, toHex(dst), toHex(want))
+			}
+			if c, err = NewCipherWithCounter(nonce, key, 20, counter); err != nil {
+				t.Fatal(err)
+			}
+			c.XORKeyStream(dst, src)
+			if !bytes.Equal(dst, want) {
+				t.Errorf("NonceSize %d: NewCipherWithCounter(%d) mismatch:\n \t got:  %s\n \t want: %s", nonceSize, counter, toHex(dst), toHex(want))
+			}
+		}
+	}
+
+	// RFC 8439 - 2.4.2
+	dst := make([]byte, len(rfc8439Plaintext))
+	XORKeyStreamAt(dst, rfc8439Plaintext, fromHex("000000000000004a00000000"), key, 20, 1)
+	if !bytes.Equal(dst, rfc8439Ciphertext) {
+		t.Errorf("RFC 8439 mismatch:\n \t got:  %s\n \t want: %s", toHex(dst), toHex(rfc8439Ciphertext))
+	}
+
+	nonce := make([]byte, INonceSize)
+	if _, err := NewCipherWithCounter(nonce, key, 20, 1<<32); err != ErrCounterOverflow {
+		t.Errorf("NewCipherWithCounter returned %v - want %v", err, ErrCounterOverflow)
+	}
+	if err := xorKeyStreamAt(dst[:64], dst[:64], nonce, key, 20, (1<<32)-1); err != nil {
+		t.Errorf("xorKeyStreamAt failed for the last block: %v", err)
+	}
+	if err := xorKeyStreamAt(dst[:65], dst[:65], nonce, key, 20, (1<<32)-1); err != ErrCounterOverflow {
+		t.Errorf("xorKeyStreamAt returned %v - want %v", err, ErrCounterOverflow)
+	}
+	if err := xorKeyStreamAt(dst[:65], dst[:65], make([]byte, NonceSize), key, 20, ^uint64(0)); err != ErrCounterOverflow {
+		t.Errorf("xorKeyStreamAt returned %v - want %v", err, ErrCounterOverflow)
+	}
+	func() {
+		defer func() {
+			if err := recover(); err != ErrCounterOverflow {
+				t.Errorf("XORKeyStreamAt panicked with %v - want %v", err, ErrCounterOverflow)
+			}
+		}()
+		XORKeyStreamAt(dst, dst, nonce, key, 20, 1<<32)
+	}()
+}
+
+func TestCounterBoundary(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	src := make([]byte, 64)
+	for i := range src {
+		src[i] = byte(i)
+	}
+
+	for _, test := range []struct {
+		nonceSize int
+		counter   uint64
+	}{
+		{nonceSize: INonceSize, counter: math.MaxUint32},
+		{nonceSize: NonceSize, counter: math.MaxUint64},
+		{nonceSize: XNonceSize, counter: math.MaxUint64},
+	} {
+		nonce := make([]byte, test.nonceSize)
+		for i := range nonce {
+			nonce[i] = byte(0x40 + i)
+		}
+		want := make([]byte, len(src))
+		XORKeyStreamAt(want, src, nonce, key, 20, test.counter)
+
+		c, err := NewCipherWithCounter(nonce, key, 20, test.counter)
+		if err != nil {
+			t.Fatalf("NonceSize %d: NewCipherWithCounter failed: %v", test.nonceSize, err)
+		}
+		dst := make([]byte, len(src))
+		if err = c.XORKeyStreamChecked(dst[:10], src[:10]); err != nil {
+			t.Fatalf("NonceSize %d: XORKeyStreamChecked failed for the last block: %v", test.nonceSize, err)
+		}
+		if ctr := c.Counter(); ctr != test.counter {
+			t.Errorf("NonceSize %d: Counter returned %d - want %d", test.nonceSize, ctr, test.counter)
+		}
+
+		// The exhausted cipher must remain exhausted after restoring it.
+		data, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatalf("NonceSize %d: MarshalBinary failed: %v", test.nonceSize, err)
+		}
+		var restored Cipher
+		if err = restored.UnmarshalBinary(data); err != nil {
+			t.Fatalf("NonceSize %d: UnmarshalBinary failed: %v", test.nonceSize, err)
+		}
+		for j, c := range []*Cipher{c, &restored} {
+			out := append([]byte(nil), dst[:10]...)
+			out = append(out, make([]byte, 54)...)
+			if err = c.XORKeyStreamChecked(out[10:], src[10:]); err != nil {
+				t.Fatalf("NonceSize %d: cipher %d: XORKeyStreamChecked failed for the last block: %v", test.nonceSize, j, err)
+			}
+			if !bytes.Equal(out, want) {
+				t.Errorf("NonceSize %d: cipher %d: keystream mismatch:\n \t got:  %s\n \t want: %s", test.nonceSize, j, toHex(out), toHex(want))
+			}
+			if err = c.XORKeyStreamChecked(out[:1], src[:1]); err != ErrCounterOverflow {
+				t.Errorf("NonceSize %d: cipher %d: XORKeyStreamChecked returned %v - want %v", test.nonceSize, j, err, ErrCounterOverflow)
+			}
+		}
+
+		// The wrapped counter must not modify the nonce.
+		c.SetCounter(0)
+		c.XORKeyStream(dst, src)
+		XORKeyStream(want, src, nonce, key, 20)
+		if !bytes.Equal(dst, want) {
+			t.Errorf("NonceSize %d: keystream mismatch after SetCounter(0):\n \t got:  %s\n \t want: %s", test.nonceSize, toHex(dst), toHex(want))
+		}
+	}
+}
+
+var rfc8439Plaintext = fromHex("4c616469657320616e642047656e746c656d656e206f662074686520636c617373206f66202739393a204966204920636f756c64206f6666657220796f75206f6e6c79206f6e652074697020666f7220746865206675747572652c2073756e73637265656e20776f756c642062652069742e")
+
+var rfc8439Ciphertext = fromHex("6e2e359a2568f98041ba0728dd0d6981e97e7aec1d4360c20a27afccfd9fae0bf91b65c5524733ab8f593dabcd62b3571639d624e65152ab8f530c359f0861d807ca0dbf500d6a6156a38e088a22b65e52bc514d16ccf806818ce91ab77937365af90bbf74a35be6b40b8eedf2785e42874d")
+
+func TestWipe(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i + 1)
+	}
+	for _, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+		c, err := NewCipher(make([]byte, nonceSize), key, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 100)
+		c.XORKeyStream(buf, buf) // leave keystream in the block buffer
+
+		c.Wipe()
+		var zero [64]byte
+		if c.state != zero || c.block != zero || c.off != 0 {
+			t.Errorf("NonceSize %d: Wipe didn't clear the cipher:\n \t state: %s\n \t block: %s", nonceSize, toHex(c.state[:]), toHex(c.block[:]))
+		}
+
+		if err = c.XORKeyStreamChecked(buf, buf); err != ErrWiped {
+			t.Errorf("NonceSize %d: XORKeyStreamChecked returned %v - want %v", nonceSize, err, ErrWiped)
+		}
+		if err = c.Seek(65); err != ErrWiped {
+			t.Errorf("NonceSize %d: Seek returned %v - want %v", nonceSize, err, ErrWiped)
+		}
+		testOverflow(nonceSize, buf, c, t)
+
+		if err = c.Close(); err != nil {
+			t.Errorf("NonceSize %d: Close failed: %v", nonceSize, err)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	key0, key1 := make([]byte, 32), make([]byte, 32)
+	for i := range key1 {
+		key1[i] = byte(i + 1)
+	}
+	nonce := make([]byte, XNonceSize)
+	for i := range nonce {
+		nonce[i] = byte(i * 3)
+	}
+	newCiphers := []func(nonce, key []byte, rounds int) (*Cipher, error){
+		NewCipher, NewCipherIETF, NewXCipher,
+	}
+	for i, newCipher := range newCiphers {
+		for _, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+			for _, rounds := range []int{8, 12, 20} {
+				c, err := newCipher(make([]byte, nonceSize), key0, rounds)
+				if err != nil {
+					continue // the constructor doesn't support this nonce size
+				}
+				c.XORKeyStream(make([]byte, 100), make([]byte, 100))
+
+				ref, err := newCipher(nonce[:nonceSize], key1, rounds)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = c.Reset(nonce[:nonceSize], key1); err != nil {
+					t.Fatalf("Test %d: Reset failed: %v", i, err)
+				}
+				if *c != *ref {
+					t.Errorf("Test %d: NonceSize %d, rounds %d: Reset and constructor produced different ciphers", i, nonceSize, rounds)
+				}
+			}
+		}
+	}
+
+	c, err := NewXCipher(nonce, key0, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := c.state
+	if err = c.Reset(nonce[:INonceSize], key1); err != ErrNonceSize {
+		t.Errorf("Reset returned %v - want %v", err, ErrNonceSize)
+	}
+	if err = c.Reset(nonce, key1[:16]); err != ErrKeySize {
+		t.Errorf("Reset returned %v - want %v", err, ErrKeySize)
+	}
+	if c.state != state {
+		t.Error("Reset modified the cipher on error")
+	}
+	c.Wipe()
+	if err = c.Reset(nonce, key1); err != ErrWiped {
+		t.Errorf("Reset returned %v - want %v", err, ErrWiped)
+	}
+}
+
+func TestClone(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for _, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+		c, err := NewCipher(make([]byte, nonceSize), key, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.KeyStream(make([]byte, 100))
+
+		clone := c.Clone()
+		want, got := make([]byte, 200), make([]byte, 200)
+		c.KeyStream(want)
+		clone.KeyStream(got)
+		if !bytes.Equal(got, want) {
+			t.Errorf("NonceSize %d: keystream mismatch:\n \t got:  %s\n \t want: %s", nonceSize, toHex(got), toHex(want))
+		}
+
+		clone.Wipe()
+		c.KeyStream(want) // the clone must be independent of c
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	defer func(sse2, ssse3, avx, avx2 bool) {
+		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
+	}(useSSE2, useSSSE3, useAVX, useAVX2)
+
+	if useAVX2 {
+		t.Log("AVX2 version")
+		testOverlap(t)
+		useAVX2 = false
+	}
+	if useAVX {
+		t.Log("AVX version")
+		testOverlap(t)
+		useAVX = false
+	}
+	if useSSSE3 {
+		t.Log("SSSE3 version")
+		testOverlap(t)
+		useSSSE3 = false
+	}
+	if useSSE2 {
+		t.Log("SSE2 version")
+		testOverlap(t)
+		useSSE2 = false
+	}
+	t.Log("generic version")
+	testOverlap(t)
+}
+
+func testOverlap(t *testing.T) {
+	key, nonce := make([]byte, KeySize), make([]byte, NonceSize)
+	buf := make([]byte, 1024)
+	for i := range buf {
+		buf[i] = byte(i)
+	}
+	orig := append([]byte(nil), buf...)
+
+	overlapTests := []struct {
+		dst, src []byte
+		err      error
+	}{
+		{dst: buf[:256], src: buf[:256], err: nil},
+		{dst: buf[:300], src: buf[:256], err: nil},
+		{dst: buf[256:512], src: buf[:256], err: nil},
+		{dst: buf[1:257], src: buf[:256], err: ErrOverlap},
+		{dst: buf[:256], src: buf[3:259], err: ErrOverlap},
+		{dst: buf[64:576], src: buf[:512], err: ErrOverlap},
+		{dst: buf[255:511], src: buf[:256], err: ErrOverlap},
+	}
+	for i, v := range overlapTests {
+		if err := XORKeyStreamChecked(v.dst, v.src, nonce, key, 20); err != v.err {
+			t.Errorf("Test %d: XORKeyStreamChecked returned %v - want %v", i, err, v.err)
+		}
+		c, err := NewCipher(nonce, key, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = c.XORKeyStreamChecked(v.dst, v.src); err != v.err {
+			t.Errorf("Test %d: Cipher.XORKeyStreamChecked returned %v - want %v", i, err, v.err)
+		}
+		if v.err == nil {
+			copy(buf, orig)
+			continue
+		}
+		if !bytes.Equal(buf, orig) {
+			t.Fatalf("Test %d: XORKeyStreamChecked modified dst", i)
+		}
+
+		func() {
+			defer func() {
+				if err := recover(); err != ErrOverlap {
+					t.Errorf("Test %d: XORKeyStream panicked with %v - want %v", i, err, ErrOverlap)
+				}
+			}()
+			XORKeyStream(v.dst, v.src, nonce, key, 20)
+		}()
+		func() {
+			defer func() {
+				if err := recover(); err != ErrOverlap {
+					t.Errorf("Test %d: Cipher.XORKeyStream panicked with %v - want %v", i, err, ErrOverlap)
+				}
+			}()
+			c.XORKeyStream(v.dst, v.src)
+		}()
+	}
+}
+
+func TestErrors(t *testing.T) {
+	key, nonce := make([]byte, KeySize), make([]byte, INonceSize)
+	buf := make([]byte, 64)
+
+	if _, err := NewCipher(nonce, key, 10); err != ErrRounds {
+		t.Errorf("NewCipher: got %v - want %v", err, ErrRounds)
+	}
+	if _, err := NewCipherIETF(nonce, key, 10); err != ErrRounds {
+		t.Errorf("NewCipherIETF: got %v - want %v", err, ErrRounds)
+	}
+	if _, err := NewXCipher(make([]byte, XNonceSize), key, 10); err != ErrRounds {
+		t.Errorf("NewXCipher: got %v - want %v", err, ErrRounds)
+	}
+	if _, err := NewCipher(nonce, key[:16], 20); err != ErrKeySize {
+		t.Errorf("NewCipher: got %v - want %v", err, ErrKeySize)
+	}
+	if _, err := NewCipher(nonce[:10], key, 20); err != ErrNonceSize {
+		t.Errorf("NewCipher: got %v - want %v", err, ErrNonceSize)
+	}
+
+	if err := XORKeyStreamChecked(buf, buf, nonce, key, 10); err != ErrRounds {
+		t.Errorf("XORKeyStreamChecked: got %v - want %v", err, ErrRounds)
+	}
+	if err := XORKeyStreamChecked(buf[:63], buf, nonce, key, 20); err != ErrShortBuffer {
+		t.Errorf("XORKeyStreamChecked: got %v - want %v", err, ErrShortBuffer)
+	}
+	if err := XORKeyStreamChecked(buf, buf, nonce, key[:16], 20); err != ErrKeySize {
+		t.Errorf("XORKeyStreamChecked: got %v - want %v", err, ErrKeySize)
+	}
+	if err := XORKeyStreamChecked(buf, buf, nonce[:10], key, 20); err != ErrNonceSize {
+		t.Errorf("XORKeyStreamChecked: got %v - want %v", err, ErrNonceSize)
+	}
+
+	c, err := NewCipher(nonce, key, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.XORKeyStreamChecked(buf[:63], buf); err != ErrShortBuffer {
+		t.Errorf("XORKeyStreamChecked: got %v - want %v", err, ErrShortBuffer)
 	}
 }
 
@@ -116,6 +606,154 @@ func testOverflow(i int, plaintext []byte, stream cipher.Stream, t *testing.T) {
 	stream.XORKeyStream(plaintext, plaintext)
 }
 
+func TestXChaChaIETF(t *testing.T) {
+	key := make([]byte, 32)
+	nonce := make([]byte, XNonceSize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i := range nonce {
+		nonce[i] = byte(0x40 + i)
+	}
+
+	var subKey [32]byte
+	var hNonce [16]byte
+	copy(subKey[:], key)
+	copy(hNonce[:], nonce)
+	HChaCha20(&subKey, &hNonce, &subKey)
+	iNonce := make([]byte, INonceSize)
+	copy(iNonce[4:], nonce[16:])
+
+	for _, ctr := range []uint64{0, 1, 1 << 16, (1 << 32) - 3} {
+		ref, stream := make([]byte, 128), make([]byte, 128)
+
+		c, err := NewCipherIETF(iNonce, subKey[:], 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetCounter(ctr)
+		c.XORKeyStream(ref, ref)
+
+		x, err := NewCipherIETF(nonce, key, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.SetCounter(ctr)
+		x.XORKeyStream(stream, stream)
+		if !bytes.Equal(stream, ref) {
+			t.Errorf("Counter %d: keystream mismatch:\n \t got:  %s\n \t want: %s", ctr, toHex(stream), toHex(ref))
+		}
+	}
+
+	if _, err := NewCipherIETF(make([]byte, NonceSize), key, 20); err == nil {
+		t.Error("NewCipherIETF accepted a 64 bit nonce")
+	}
+
+	// The 64 bit counter of NewCipher crosses the 2^32 boundary,
+	// the IETF version must reject it.
+	buf := make([]byte, 128)
+	c, _ := NewCipher(nonce, key, 20)
+	c.SetCounter((1 << 32) - 1)
+	c.XORKeyStream(buf, buf)
+
+	x, _ := NewCipherIETF(nonce, key, 20)
+	x.SetCounter((1 << 32) - 1)
+	testOverflow(0, buf, x, t)
+}
+
+func TestXCipher(t *testing.T) {
+	key := make([]byte, 32)
+	nonce := make([]byte, XNonceSize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i := range nonce {
+		nonce[i] = byte(0x40 + i)
+	}
+
+	for _, rounds := range []int{8, 12, 20} {
+		var subKey [32]byte
+		var hNonce [16]byte
+		copy(subKey[:], key)
+		copy(hNonce[:], nonce)
+		HChaCha(&subKey, &hNonce, &subKey, rounds)
+
+		ref, stream := make([]byte, 256), make([]byte, 256)
+		XORKeyStream(ref, ref, nonce[16:], subKey[:], rounds)
+
+		c, err := NewXCipher(nonce, key, rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.XORKeyStream(stream, stream)
+		if !bytes.Equal(stream, ref) {
+			t.Errorf("Rounds %d: keystream mismatch:\n \t got:  %s\n \t want: %s", rounds, toHex(stream), toHex(ref))
+		}
+
+		// NewCipher always derives the sub-key using HChaCha20.
+		old, err := NewCipher(nonce, key, rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		old.XORKeyStream(stream, make([]byte, len(stream)))
+		if equal := bytes.Equal(stream, ref); equal != (rounds == 20) {
+			t.Errorf("Rounds %d: NewCipher and NewXCipher keystreams equal: %v", rounds, equal)
+		}
+	}
+
+	if _, err := NewXCipher(make([]byte, INonceSize), key, 12); err == nil {
+		t.Error("NewXCipher accepted a 96 bit nonce")
+	}
+}
+
+func TestSeek(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	for _, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+		nonce := make([]byte, nonceSize)
+		ref := make([]byte, 1024)
+		XORKeyStream(ref, ref, nonce, key, 20)
+
+		c, err := NewCipher(nonce, key, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, off := range []uint64{0, 1, 31, 63, 64, 65, 129, 500, 960} {
+			// consume some keystream to ensure Seek doesn't depend on the current position
+			c.XORKeyStream(make([]byte, 17), make([]byte, 17))
+
+			if err := c.Seek(off); err != nil {
+				t.Fatalf("NonceSize %d: Seek(%d) failed: %v", nonceSize, off, err)
+			}
+			stream := make([]byte, 64)
+			c.XORKeyStream(stream[:1], stream[:1])
+			c.XORKeyStream(stream[1:], stream[1:])
+			if !bytes.Equal(stream, ref[off:off+64]) {
+				t.Fatalf("NonceSize %d: Seek(%d) keystream mismatch:\n \t got:  %s\n \t want: %s", nonceSize, off, toHex(stream), toHex(ref[off:off+64]))
+			}
+		}
+	}
+
+	c, err := NewCipher(make([]byte, INonceSize), key, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Seek(((1 << 32) - 1) * 64); err != nil {
+		t.Errorf("Seek to the end of the keystream failed: %v", err)
+	}
+	if err = c.Seek((1 << 32) * 64); err != ErrCounterOverflow {
+		t.Errorf("Seek beyond the end of the keystream returned %v - want %v", err, ErrCounterOverflow)
+	}
+	if err = c.Seek(((1<<32)-1)*64 + 1); err != nil {
+		t.Fatalf("Seek into the last block failed: %v", err)
+	}
+	c.XORKeyStream(make([]byte, 63), make([]byte, 63))
+	testOverflow(0, make([]byte, 1), c, t)
+}
+
 func TestIncremental(t *testing.T) {
 	defer func(sse2, ssse3, avx, avx2 bool) {
 		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
@@ -142,14 +780,108 @@ func TestIncremental(t *testing.T) {
 	}
 }
 
-func testHChaCha20(t *testing.T) {
-	for i, v := range hChaCha20Vectors {
+func TestKeyStream(t *testing.T) {
+	defer func(sse2, ssse3, avx, avx2 bool) {
+		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
+	}(useSSE2, useSSSE3, useAVX, useAVX2)
+
+	if useAVX2 {
+		t.Log("AVX2 version")
+		testKeyStream(t, 1100)
+		useAVX2 = false
+	}
+	if useAVX {
+		t.Log("AVX version")
+		testKeyStream(t, 1100)
+		useAVX = false
+	}
+	if useSSSE3 {
+		t.Log("SSSE3 version")
+		testKeyStream(t, 1100)
+		useSSSE3 = false
+	}
+	if useSSE2 {
+		t.Log("SSE2 version")
+		testKeyStream(t, 1100)
+		useSSE2 = false
+	}
+	t.Log("generic version")
+	testKeyStream(t, 1100)
+}
+
+// TestSetCounterKeyStream checks that the keystream after SetCounter - as
+// used by Jump and Split of the rand package - doesn't depend on the SIMD
+// implementation.
+func TestSetCounterKeyStream(t *testing.T) {
+	defer func(sse2, ssse3, avx, avx2 bool) {
+		useSSE2, useSSSE3, useAVX, useAVX2 = sse2, ssse3, avx, avx2
+	}(useSSE2, useSSSE3, useAVX, useAVX2)
+
+	if useAVX2 {
+		t.Log("AVX2 version")
+		testSetCounterKeyStream(t)
+		useAVX2 = false
+	}
+	if useAVX {
+		t.Log("AVX version")
+		testSetCounterKeyStream(t)
+		useAVX = false
+	}
+	if useSSSE3 {
+		t.Log("SSSE3 version")
+		testSetCounterKeyStream(t)
+		useSSSE3 = false
+	}
+	if useSSE2 {
+		t.Log("SSE2 version")
+		testSetCounterKeyStream(t)
+		useSSE2 = false
+	}
+	t.Log("generic version")
+	testSetCounterKeyStream(t)
+}
+
+func testSetCounterKeyStream(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	stream, ref := make([]byte, 512), make([]byte, 512)
+	for _, rounds := range []int{8, 12, 20} {
+		for _, n := range []uint64{0, 1, 8} {
+			nonce := make([]byte, NonceSize)
+			binary.LittleEndian.PutUint64(nonce, n)
+			for _, ctr := range []uint64{0, 1000, (1 << 32) - 3, math.MaxUint64 - 7} {
+				c, err := NewCipher(nonce, key, rounds)
+				if err != nil {
+					t.Fatal(err)
+				}
+				c.KeyStream(stream[:10])
+				c.SetCounter(ctr)
+				c.KeyStream(stream)
+
+				var block, state [64]byte
+				if err = setup(&state, nonce, key, 20); err != nil {
+					t.Fatal(err)
+				}
+				setCounter(&state, ctr, NonceSize)
+				keyStreamGeneric(ref, &block, &state, rounds)
+				if !bytes.Equal(stream, ref) {
+					t.Fatalf("Rounds %d: nonce %d: SetCounter(%d) keystream mismatch:\n \t got:  %s\n \t want: %s", rounds, n, ctr, toHex(stream), toHex(ref))
+				}
+			}
+		}
+	}
+}
+
+func testHChaCha(t *testing.T) {
+	for i, v := range hChaChaVectors {
 		var key [32]byte
 		var nonce [16]byte
 		copy(key[:], v.key)
 		copy(nonce[:], v.nonce)
 
-		hChaCha20(&key, &nonce, &key)
+		hChaCha(&key, &nonce, &key, v.rounds)
 		if !bytes.Equal(key[:], v.keystream) {
 			t.Errorf("Test %d: keystream mismatch:\n \t got:  %s\n \t want: %s", i, toHex(key[:]), toHex(v.keystream))
 		}
@@ -181,6 +913,44 @@ func testVectors(t *testing.T) {
 	}
 }
 
+func testKeyStream(t *testing.T, size int) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	zero, ref := make([]byte, size), make([]byte, size)
+	stream := make([]byte, size+1)
+
+	for i, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+		nonce := make([]byte, nonceSize)
+		rounds := []int{8, 12, 20}[i]
+
+		for j := 0; j <= size; j++ {
+			XORKeyStream(ref[:j], zero[:j], nonce, key, rounds)
+
+			stream[j] = 0xff
+			KeyStream(stream[:j], nonce, key, rounds)
+			if !bytes.Equal(stream[:j], ref[:j]) {
+				t.Fatalf("NonceSize %d: KeyStream of %d bytes mismatch:\n \t got:  %s\n \t want: %s", nonceSize, j, toHex(stream[:j]), toHex(ref[:j]))
+			}
+			if stream[j] != 0xff {
+				t.Fatalf("NonceSize %d: KeyStream of %d bytes modified dst[%d]", nonceSize, j, j)
+			}
+
+			c, err := NewCipher(nonce, key, rounds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.KeyStream(stream[:j/3])
+			c.KeyStream(stream[j/3 : j/2])
+			c.KeyStream(stream[j/2 : j])
+			if !bytes.Equal(stream[:j], ref[:j]) {
+				t.Fatalf("NonceSize %d: Cipher.KeyStream of %d bytes mismatch:\n \t got:  %s\n \t want: %s", nonceSize, j, toHex(stream[:j]), toHex(ref[:j]))
+			}
+		}
+	}
+}
+
 func testIncremental(t *testing.T, iter int, size int) {
 	sse2, ssse3, avx, avx2 := useSSE2, useSSSE3, useAVX, useAVX2
 	msg, ref, stream := make([]byte, size), make([]byte, size), make([]byte, size)
@@ -231,28 +1001,81 @@ func testIncremental(t *testing.T, iter int, size int) {
 	}
 }
 
-var hChaCha20Vectors = []struct {
+var hChaChaVectors = []struct {
 	key, nonce, keystream []byte
+	rounds                int
 }{
 	{
 		fromHex("0000000000000000000000000000000000000000000000000000000000000000"),
 		fromHex("000000000000000000000000000000000000000000000000"),
 		fromHex("1140704c328d1d5d0e30086cdf209dbd6a43b8f41518a11cc387b669b2ee6586"),
+		20,
 	},
 	{
 		fromHex("8000000000000000000000000000000000000000000000000000000000000000"),
 		fromHex("000000000000000000000000000000000000000000000000"),
 		fromHex("7d266a7fd808cae4c02a0a70dcbfbcc250dae65ce3eae7fc210f54cc8f77df86"),
+		20,
 	},
 	{
 		fromHex("0000000000000000000000000000000000000000000000000000000000000001"),
 		fromHex("000000000000000000000000000000000000000000000002"),
 		fromHex("e0c77ff931bb9163a5460c02ac281c2b53d792b1c43fea817e9ad275ae546963"),
+		20,
 	},
 	{
 		fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
 		fromHex("000102030405060708090a0b0c0d0e0f1011121314151617"),
 		fromHex("51e3ff45a895675c4b33b46c64f4a9ace110d34df6a2ceab486372bacbd3eff6"),
+		20,
+	},
+	{
+		fromHex("0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("00000000000000000000000000000000"),
+		fromHex("367c2a0999f0d8204ff26b99ebc0626a3a629f2ca0de6919610be82f411326be"),
+		12,
+	},
+	{
+		fromHex("8000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("00000000000000000000000000000000"),
+		fromHex("132453f68252ad720732a64edf29f2ba68e48c086daad356edce3a3f988d8e82"),
+		12,
+	},
+	{
+		fromHex("0000000000000000000000000000000000000000000000000000000000000001"),
+		fromHex("00000000000000000000000000000002"),
+		fromHex("bf14f8f27ac9c6674a4ad3809f8ef6381ba2e24d43f2d297f427b940f17f9e77"),
+		12,
+	},
+	{
+		fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		fromHex("000102030405060708090a0b0c0d0e0f"),
+		fromHex("9f545c9511414b94348ecc200bb69dd81afa38c5edb9dd9719372fb045cb9d0d"),
+		12,
+	},
+	{
+		fromHex("0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("00000000000000000000000000000000"),
+		fromHex("d9877ece1bfb1fa34d2e566faba38436314a42a3da86b001387bfdb80e0cfe42"),
+		8,
+	},
+	{
+		fromHex("8000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("00000000000000000000000000000000"),
+		fromHex("613d54ff42ce41d12bb4622a844ccd96dac6f7dac891c9ee93448492a7e9c9ff"),
+		8,
+	},
+	{
+		fromHex("0000000000000000000000000000000000000000000000000000000000000001"),
+		fromHex("00000000000000000000000000000002"),
+		fromHex("53837b835303c03fa6e74d8905cde153c372b8f8e1804db8fda9d45c81cd3b38"),
+		8,
+	},
+	{
+		fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		fromHex("000102030405060708090a0b0c0d0e0f"),
+		fromHex("beb00bc69350c503243dd175b5d364a1d95273228258a8acebe65d88dfae135d"),
+		8,
 	},
 }
 
@@ -161,3 +161,35 @@
 	VMOVDQU    t0, 0(dst);      \
 	VPERM2I128 $49, v3, v2, t0; \
 	VMOVDQU    t0, 32(dst)
+
+// STORE_SSE writes the 4x16 byte XMM registers
+// v0 - v3 to dst at off.
+#define STORE_SSE(dst, off, v0, v1, v2, v3) \
+	MOVOU v0, 0+off(dst);  \
+	MOVOU v1, 16+off(dst); \
+	MOVOU v2, 32+off(dst); \
+	MOVOU v3, 48+off(dst)
+
+// STORE_AVX writes the 4x16 byte XMM registers
+// v0 - v3 to dst at off.
+#define STORE_AVX(dst, off, v0, v1, v2, v3) \
+	VMOVDQU v0, 0+off(dst);  \
+	VMOVDQU v1, 16+off(dst); \
+	VMOVDQU v2, 32+off(dst); \
+	VMOVDQU v3, 48+off(dst)
+
+#define STORE_AVX2(dst, off, v0, v1, v2, v3, t) \
+	VPERM2I128 $32, v1, v0, t;   \
+	VMOVDQU    t, (0+off)(dst);  \
+	VPERM2I128 $32, v3, v2, t;   \
+	VMOVDQU    t, (32+off)(dst); \
+	VPERM2I128 $49, v1, v0, t;   \
+	VMOVDQU    t, (64+off)(dst); \
+	VPERM2I128 $49, v3, v2, t;   \
+	VMOVDQU    t, (96+off)(dst)
+
+#define STORE_UPPER_AVX2(dst, off, v0, v1, v2, v3, t) \
+	VPERM2I128 $32, v1, v0, t;  \
+	VMOVDQU    t, (0+off)(dst); \
+	VPERM2I128 $32, v3, v2, t;  \
+	VMOVDQU    t, (32+off)(dst)
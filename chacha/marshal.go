@@ -0,0 +1,213 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// The binary encoding of a Cipher (version 1):
+//
+//	version | flags | rounds | nonce size | offset | HChaCha rounds | counter and nonce | [HChaCha nonce] | [key]
+//	1 byte  | 1 byte| 1 byte | 1 byte     | 1 byte | 1 byte         | 16 bytes          | 16 bytes        | 32 bytes
+//
+// The nonce size is either NonceSize (64 bit counter) or INonceSize (32 bit counter).
+// The HChaCha nonce is present iff the HChaCha rounds are not 0 (XChaCha) and the key
+// is present iff the flagKey bit is set. The flags also contain the constructor of
+// the cipher (variantMask), such that Reset behaves the same after restoring the cipher.
+// The flagExhausted bit is set iff the last block of the keystream period has been
+// generated - then the counter has wrapped to 0. For XChaCha the key is the derived sub-key.
+// The buffered keystream is not encoded - it is generated again from the key.
+const (
+	encodingVersion = 1
+	headerSize      = 6 + 16
+
+	flagKey       = 1 << 0
+	variantShift  = 1
+	variantMask   = 3 << variantShift
+	flagExhausted = 1 << 3
+)
+
+var (
+	errEncoding   = errors.New("chacha20/chacha: invalid binary encoding")
+	errVersion    = errors.New("chacha20/chacha: unsupported binary encoding version")
+	errMissingKey = errors.New("chacha20/chacha: binary encoding doesn't contain the key")
+	errKeyPresent = errors.New("chacha20/chacha: binary encoding already contains a key")
+)
+
+// MarshalBinary returns the binary encoding of the cipher state including the
+// key, the counter, the offset within the current block, the number of rounds
+// and the nonce variant. The returned data must be kept secret.
+// It implements the encoding.BinaryMarshaler interface.
+func (c *Cipher) MarshalBinary() ([]byte, error) { return c.marshal(true) }
+
+// MarshalBinaryWithoutKey behaves like MarshalBinary but doesn't include the key.
+// The key must be passed to UnmarshalBinaryWithKey to restore the cipher.
+// The encoding doesn't contain any secret data but is bound to the nonce.
+func (c *Cipher) MarshalBinaryWithoutKey() ([]byte, error) { return c.marshal(false) }
+
+// UnmarshalBinary restores the cipher state from data produced by MarshalBinary.
+// It implements the encoding.BinaryUnmarshaler interface.
+func (c *Cipher) UnmarshalBinary(data []byte) error { return c.unmarshal(data, nil) }
+
+// UnmarshalBinaryWithKey restores the cipher state from data produced by
+// MarshalBinaryWithoutKey. The key must be the key originally passed to
+// the constructor of the cipher - for XChaCha the sub-key is derived again.
+func (c *Cipher) UnmarshalBinaryWithKey(data, key []byte) error {
+	if len(key) != KeySize {
+		return ErrKeySize
+	}
+	return c.unmarshal(data, key)
+}
+
+func (c *Cipher) marshal(withKey bool) ([]byte, error) {
+	if c.rounds == 0 {
+		return nil, ErrWiped
+	}
+
+	size := headerSize
+	if c.hRounds != 0 {
+		size += 16
+	}
+	if withKey {
+		size += KeySize
+	}
+	data := make([]byte, headerSize, size)
+	data[0] = encodingVersion
+	if withKey {
+		data[1] |= flagKey
+	}
+	data[1] |= byte(c.variant << variantShift)
+	if c.exhausted {
+		data[1] |= flagExhausted
+	}
+	data[2] = byte(c.rounds)
+	data[3] = byte(c.noncesize)
+	data[4] = byte(c.off)
+	data[5] = byte(c.hRounds)
+	copy(data[6:], c.state[48:])
+
+	if c.hRounds != 0 {
+		data = append(data, c.hNonce[:]...)
+	}
+	if withKey {
+		data = append(data, c.state[16:48]...)
+	}
+	return data, nil
+}
+
+func (c *Cipher) unmarshal(data, key []byte) error {
+	if len(data) < headerSize {
+		return errEncoding
+	}
+	if data[0] != encodingVersion {
+		return errVersion
+	}
+	withKey := data[1]&flagKey != 0
+	exhausted := data[1]&flagExhausted != 0
+	variant := int(data[1]&variantMask) >> variantShift
+	if data[1]&^(flagKey|variantMask|flagExhausted) != 0 || variant > variantX {
+		return errEncoding
+	}
+	if withKey && key != nil {
+		return errKeyPresent
+	}
+	if !withKey && key == nil {
+		return errMissingKey
+	}
+
+	rounds, noncesize, off, hRounds := int(data[2]), int(data[3]), int(data[4]), int(data[5])
+	if rounds != 20 && rounds != 12 && rounds != 8 {
+		return errEncoding
+	}
+	if noncesize != NonceSize && noncesize != INonceSize {
+		return errEncoding
+	}
+	switch variant { // the variant must match the values set by reset
+	case variantX:
+		if hRounds != rounds || noncesize != NonceSize {
+			return errEncoding
+		}
+	case variantIETF:
+		if noncesize != INonceSize || (hRounds != 0 && hRounds != 20) {
+			return errEncoding
+		}
+	default:
+		if hRounds != 0 && (hRounds != 20 || noncesize != NonceSize) {
+			return errEncoding
+		}
+	}
+	if off >= 64 {
+		return errEncoding
+	}
+
+	size := headerSize
+	if hRounds != 0 {
+		size += 16
+	}
+	if withKey {
+		size += KeySize
+	}
+	if len(data) != size {
+		return errEncoding
+	}
+
+	var state [64]byte
+	copy(state[48:], data[6:headerSize])
+	var ctr uint64
+	if noncesize == INonceSize {
+		ctr = uint64(binary.LittleEndian.Uint32(state[48:]))
+	} else {
+		ctr = binary.LittleEndian.Uint64(state[48:])
+	}
+	if exhausted && ctr != 0 {
+		return errEncoding // the counter of an exhausted cipher has wrapped to 0
+	}
+	if off > 0 && ctr == 0 && !exhausted {
+		return errEncoding // the counter points behind the current block
+	}
+
+	var hNonce [16]byte
+	rest := data[headerSize:]
+	if hRounds != 0 {
+		copy(hNonce[:], rest)
+		rest = rest[16:]
+	}
+	if withKey {
+		key = rest
+	} else if hRounds != 0 {
+		var subKey [32]byte
+		copy(subKey[:], key)
+		hChaCha(&subKey, &hNonce, &subKey, hRounds)
+		defer wipe(subKey[:])
+		key = subKey[:]
+	}
+
+	var nonce [16]byte
+	copy(nonce[:], state[48:])
+	initialize(&state, key, &nonce)
+
+	c.Wipe()
+	c.state = state
+	c.rounds, c.noncesize, c.variant = rounds, noncesize, variant
+	c.hNonce, c.hRounds = hNonce, hRounds
+	if off > 0 {
+		// generate the keystream of the current block again
+		if exhausted && noncesize == INonceSize {
+			ctr = 1 << 32
+		}
+		c.SetCounter(ctr - 1)
+		var block [64]byte
+		keyStream(block[:], &(c.block), &(c.state), c.rounds)
+		c.checkExhausted()
+		c.block = block
+		c.off = off
+	} else {
+		c.exhausted = exhausted
+	}
+	wipe(state[:])
+	return nil
+}
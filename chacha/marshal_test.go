@@ -0,0 +1,170 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"bytes"
+	"encoding"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Cipher)(nil)
+	_ encoding.BinaryUnmarshaler = (*Cipher)(nil)
+)
+
+func TestMarshalBinary(t *testing.T) {
+	key := make([]byte, 32)
+	nonce := make([]byte, XNonceSize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i := range nonce {
+		nonce[i] = byte(255 - i)
+	}
+	newCiphers := []func(rounds int) (*Cipher, error){
+		func(r int) (*Cipher, error) { return NewCipher(nonce[:NonceSize], key, r) },
+		func(r int) (*Cipher, error) { return NewCipher(nonce[:INonceSize], key, r) },
+		func(r int) (*Cipher, error) { return NewCipher(nonce, key, r) },
+		func(r int) (*Cipher, error) { return NewCipherIETF(nonce, key, r) },
+		func(r int) (*Cipher, error) { return NewXCipher(nonce, key, r) },
+	}
+
+	for i, newCipher := range newCiphers {
+		for _, rounds := range []int{8, 12, 20} {
+			for _, off := range []int{0, 1, 63, 64, 100, 1000} {
+				ref, err := newCipher(rounds)
+				if err != nil {
+					t.Fatal(err)
+				}
+				c, err := newCipher(rounds)
+				if err != nil {
+					t.Fatal(err)
+				}
+				c.SetCounter(1)
+				ref.SetCounter(1)
+				c.KeyStream(make([]byte, off))
+				ref.KeyStream(make([]byte, off))
+				want := make([]byte, 300)
+				ref.KeyStream(want)
+
+				data, err := c.MarshalBinary()
+				if err != nil {
+					t.Fatalf("Test %d: MarshalBinary failed: %v", i, err)
+				}
+				keyless, err := c.MarshalBinaryWithoutKey()
+				if err != nil {
+					t.Fatalf("Test %d: MarshalBinaryWithoutKey failed: %v", i, err)
+				}
+				if bytes.Contains(keyless, c.state[16:48]) {
+					t.Fatalf("Test %d: MarshalBinaryWithoutKey returned the key", i)
+				}
+
+				var c0, c1 Cipher
+				if err := c0.UnmarshalBinary(data); err != nil {
+					t.Fatalf("Test %d: UnmarshalBinary failed: %v", i, err)
+				}
+				if err := c1.UnmarshalBinaryWithKey(keyless, key); err != nil {
+					t.Fatalf("Test %d: UnmarshalBinaryWithKey failed: %v", i, err)
+				}
+				if c0.Counter() != c.Counter() || c1.Counter() != c.Counter() {
+					t.Errorf("Test %d: Counter mismatch: got %d and %d - want %d", i, c0.Counter(), c1.Counter(), c.Counter())
+				}
+				for j, c := range []*Cipher{&c0, &c1} {
+					out := make([]byte, len(want))
+					c.KeyStream(out)
+					if !bytes.Equal(out, want) {
+						t.Errorf("Test %d: rounds: %d offset: %d cipher %d: keystream mismatch:\n \t got:  %s\n \t want: %s", i, rounds, off, j, toHex(out), toHex(want))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	key := make([]byte, 32)
+	c, err := NewCipher(make([]byte, XNonceSize), key, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.KeyStream(make([]byte, 10))
+	data, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyless, err := c.MarshalBinaryWithoutKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c0 Cipher
+	if err := c0.UnmarshalBinary(keyless); err != errMissingKey {
+		t.Errorf("UnmarshalBinary returned %v - want %v", err, errMissingKey)
+	}
+	if err := c0.UnmarshalBinaryWithKey(data, key); err != errKeyPresent {
+		t.Errorf("UnmarshalBinaryWithKey returned %v - want %v", err, errKeyPresent)
+	}
+	if err := c0.UnmarshalBinaryWithKey(keyless, key[:16]); err != ErrKeySize {
+		t.Errorf("UnmarshalBinaryWithKey returned %v - want %v", err, ErrKeySize)
+	}
+
+	marshal := func(c *Cipher, err error) []byte {
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	chachaData := marshal(NewCipher(make([]byte, NonceSize), key, 20))
+	xChaCha12Data := marshal(NewXCipher(make([]byte, XNonceSize), key, 12))
+
+	modifyData := func(data []byte, i int, v byte) []byte {
+		b := append([]byte(nil), data...)
+		b[i] = v
+		return b
+	}
+	modify := func(i int, v byte) []byte { return modifyData(data, i, v) }
+	invalid := [][]byte{
+		nil,
+		data[:len(data)-1],
+		append(data, 0),
+		modify(1, 16), // unknown flag
+		modify(1, 7),  // unknown variant
+		modify(2, 10), // rounds
+		modify(3, 24), // nonce size
+		modify(4, 64), // offset
+		modify(5, 7),  // HChaCha rounds
+		modify(5, 0),  // length doesn't match the missing HChaCha nonce
+		modify(6, 0),  // counter is 0 but offset is 10
+		modify(1, 9),  // exhausted but the counter is not 0
+
+		// the variant doesn't match the other fields
+		modify(5, 12),         // HChaCha rounds of NewCipher
+		modify(3, INonceSize), // 32 bit counter for XChaCha of NewCipher
+		modify(1, flagKey|variantIETF<<variantShift),              // 64 bit counter for NewCipherIETF
+		modifyData(chachaData, 1, flagKey|variantX<<variantShift), // no HChaCha nonce for NewXCipher
+		modifyData(xChaCha12Data, 5, 20),                          // HChaCha rounds don't match the rounds of NewXCipher
+	}
+	for i, b := range invalid {
+		if err := c0.UnmarshalBinary(b); err != errEncoding {
+			t.Errorf("Test %d: UnmarshalBinary returned %v - want %v", i, err, errEncoding)
+		}
+	}
+	if err := c0.UnmarshalBinary(modify(0, 2)); err != errVersion {
+		t.Errorf("UnmarshalBinary returned %v - want %v", err, errVersion)
+	}
+
+	c.Wipe()
+	if _, err := c.MarshalBinary(); err != ErrWiped {
+		t.Errorf("MarshalBinary returned %v - want %v", err, ErrWiped)
+	}
+	if _, err := c.MarshalBinaryWithoutKey(); err != ErrWiped {
+		t.Errorf("MarshalBinaryWithoutKey returned %v - want %v", err, ErrWiped)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minParallelChunk is the minimal number of bytes processed by one
+// goroutine. Smaller chunks don't amortize the cost of the goroutine.
+// It must be a multiple of 64.
+const minParallelChunk = 64 * 1024
+
+// ParallelXORKeyStream behaves like XORKeyStream but splits src into chunks
+// of 64 byte blocks and en/decrypts the chunks concurrently using up to
+// workers goroutines. Every goroutine starts at the block counter of its
+// chunk - so the output is identical to the output of XORKeyStream.
+// If workers <= 0 runtime.GOMAXPROCS(0) goroutines are used. Every chunk
+// contains at least 64 KB - so small buffers are processed by the calling
+// goroutine.
+// The src and dst may be the same slice but otherwise must not overlap.
+// The function panics for the same arguments as XORKeyStream.
+func ParallelXORKeyStream(dst, src, nonce, key []byte, rounds, workers int) {
+	if err := parallelXORKeyStream(dst, src, nonce, key, rounds, workers); err != nil {
+		panic(err)
+	}
+}
+
+func parallelXORKeyStream(dst, src, nonce, key []byte, rounds, workers int) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunk := ((len(src)/workers + 63) / 64) * 64
+	if chunk < minParallelChunk {
+		chunk = minParallelChunk
+	}
+	if workers == 1 || len(src) <= chunk {
+		return xorKeyStreamAt(dst, src, nonce, key, rounds, 0)
+	}
+
+	if err := checkXORKeyStream(dst, src, nonce, rounds, 0); err != nil {
+		return err
+	}
+	var state [64]byte
+	if err := setup(&state, nonce, key, 20); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for off := 0; off < len(src); off += chunk {
+		end := off + chunk
+		if end > len(src) {
+			end = len(src)
+		}
+		wg.Add(1)
+		go func(dst, src []byte, state [64]byte, counter uint64) {
+			defer wg.Done()
+
+			var block [64]byte
+			setCounter(&state, counter, len(nonce))
+			xorKeyStream(dst, src, &block, &state, rounds)
+			wipe(state[:])
+			wipe(block[:])
+		}(dst[off:end], src[off:end], state, uint64(off/64))
+	}
+	wg.Wait()
+	wipe(state[:])
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParallelXORKeyStream(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	src := make([]byte, 4*minParallelChunk+13)
+	for i := range src {
+		src[i] = byte(i * 3)
+	}
+
+	for _, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+		nonce := make([]byte, nonceSize)
+		for _, size := range []int{0, 1, 64, 1000, minParallelChunk, minParallelChunk + 1, 2*minParallelChunk + 65, len(src)} {
+			want := make([]byte, size)
+			XORKeyStream(want, src[:size], nonce, key, 12)
+
+			for _, workers := range []int{0, 1, 2, 3, 7} {
+				dst := make([]byte, size)
+				ParallelXORKeyStream(dst, src[:size], nonce, key, 12, workers)
+				if !bytes.Equal(dst, want) {
+					t.Fatalf("NonceSize %d: size %d workers %d: ParallelXORKeyStream differs from XORKeyStream", nonceSize, size, workers)
+				}
+
+				copy(dst, src)
+				ParallelXORKeyStream(dst, dst, nonce, key, 12, workers)
+				if !bytes.Equal(dst, want) {
+					t.Fatalf("NonceSize %d: size %d workers %d: in-place ParallelXORKeyStream differs from XORKeyStream", nonceSize, size, workers)
+				}
+			}
+		}
+	}
+
+	buf := make([]byte, 4*minParallelChunk)
+	for _, test := range []struct {
+		dst, src, nonce []byte
+		rounds          int
+		err             error
+	}{
+		{dst: buf, src: buf, nonce: make([]byte, NonceSize), rounds: 10, err: ErrRounds},
+		{dst: buf[1:], src: buf, nonce: make([]byte, NonceSize), rounds: 20, err: ErrShortBuffer},
+		{dst: buf[64:], src: buf[:len(buf)-64], nonce: make([]byte, NonceSize), rounds: 20, err: ErrOverlap},
+		{dst: buf, src: buf, nonce: make([]byte, 16), rounds: 20, err: ErrNonceSize},
+	} {
+		if err := parallelXORKeyStream(test.dst, test.src, test.nonce, key, test.rounds, 4); err != test.err {
+			t.Errorf("parallelXORKeyStream returned %v - want %v", err, test.err)
+		}
+	}
+}
+
+func benchmarkParallelXORKeyStream(b *testing.B, size, workers int) {
+	key, nonce := make([]byte, 32), make([]byte, NonceSize)
+	buf := make([]byte, size)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ParallelXORKeyStream(buf, buf, nonce, key, 20, workers)
+	}
+}
+
+func BenchmarkParallelXORKeyStream_16M_1(b *testing.B) { benchmarkParallelXORKeyStream(b, 16<<20, 1) }
+func BenchmarkParallelXORKeyStream_16M(b *testing.B)   { benchmarkParallelXORKeyStream(b, 16<<20, 0) }
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	errWhence   = errors.New("chacha20/chacha: invalid whence")
+	errReadSize = errors.New("chacha20/chacha: size exceeds the keystream period")
+)
+
+// Reader decrypts ChaCha20/r ciphertext read from an underlying io.ReaderAt.
+// It implements io.Reader, io.ReaderAt and io.Seeker and can decrypt the
+// ciphertext at any byte offset without processing the preceding bytes.
+type Reader struct {
+	r      io.ReaderAt
+	size   int64
+	off    int64
+	cipher Cipher
+}
+
+// NewReader returns a new *chacha.Reader decrypting the first size bytes
+// of r using the given nonce, key and number of rounds. The nonce, key and
+// rounds arguments are interpreted as by NewCipher. If the size exceeds the
+// keystream period of the ChaCha version, a non-nil error is returned.
+func NewReader(r io.ReaderAt, size int64, nonce, key []byte, rounds int) (*Reader, error) {
+	c, err := NewCipher(nonce, key, rounds)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 || (c.noncesize == INonceSize && size > (1<<32)*64) {
+		return nil, errReadSize
+	}
+	return &Reader{r: r, size: size, cipher: *c}, nil
+}
+
+// Size returns the size of the ciphertext in bytes.
+func (r *Reader) Size() int64 { return r.size }
+
+// Read reads and decrypts up to len(p) bytes at the current offset.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 && r.off < r.size {
+		err = nil
+	}
+	return
+}
+
+// ReadAt reads and decrypts len(p) bytes starting at offset off.
+// It returns ErrOffset if off is negative. It can be called concurrently as long as the underlying io.ReaderAt
+// supports concurrent calls.
+func (r *Reader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrOffset
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if max := r.size - off; int64(len(p)) > max {
+		p = p[:max]
+		n, err = r.r.ReadAt(p, off)
+		if err == nil {
+			err = io.EOF
+		}
+	} else {
+		n, err = r.r.ReadAt(p, off)
+	}
+	r.decrypt(p[:n], off)
+	return
+}
+
+// Seek sets the offset for the next Read to offset, interpreted
+// according to whence as specified by io.Seeker. It returns
+// ErrOffset if the resulting offset is negative.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errWhence
+	}
+	if offset < 0 {
+		return 0, ErrOffset
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// decrypt decrypts p using the keystream starting at offset off.
+func (r *Reader) decrypt(p []byte, off int64) {
+	if len(p) == 0 {
+		return
+	}
+	c := r.cipher
+	if err := c.Seek(uint64(off)); err != nil {
+		panic(err) // NewReader ensures that size doesn't exceed the keystream period
+	}
+	c.XORKeyStream(p, p)
+}
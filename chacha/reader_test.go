@@ -0,0 +1,118 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package chacha
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	plaintext := make([]byte, 1000)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 7)
+	}
+
+	for _, nonceSize := range []int{NonceSize, INonceSize, XNonceSize} {
+		nonce := make([]byte, nonceSize)
+		for _, rounds := range []int{8, 12, 20} {
+			ciphertext := make([]byte, len(plaintext))
+			XORKeyStream(ciphertext, plaintext, nonce, key, rounds)
+
+			r, err := NewReader(bytes.NewReader(ciphertext), int64(len(ciphertext)), nonce, key, rounds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, off := range []int{0, 1, 63, 64, 65, 127, 500, 999} {
+				for _, size := range []int{0, 1, 63, 64, 65, 200} {
+					want := plaintext[off:]
+					if len(want) > size {
+						want = want[:size]
+					}
+					buf := make([]byte, size)
+					n, err := r.ReadAt(buf, int64(off))
+					if n != len(want) || (n < size && err != io.EOF) || (n == size && err != nil) {
+						t.Fatalf("NonceSize %d, Rounds %d: ReadAt(%d, %d) returned %d, %v", nonceSize, rounds, size, off, n, err)
+					}
+					if !bytes.Equal(buf[:n], want) {
+						t.Fatalf("NonceSize %d, Rounds %d: ReadAt(%d, %d) mismatch:\n \t got:  %s\n \t want: %s", nonceSize, rounds, size, off, toHex(buf[:n]), toHex(want))
+					}
+				}
+			}
+
+			if _, err = r.Seek(-100, io.SeekEnd); err != nil {
+				t.Fatal(err)
+			}
+			rest, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(rest, plaintext[len(plaintext)-100:]) {
+				t.Fatalf("NonceSize %d, Rounds %d: Read after Seek mismatch:\n \t got:  %s\n \t want: %s", nonceSize, rounds, toHex(rest), toHex(plaintext[len(plaintext)-100:]))
+			}
+		}
+	}
+}
+
+func TestReaderSeek(t *testing.T) {
+	key, nonce := make([]byte, 32), make([]byte, INonceSize)
+	r, err := NewReader(bytes.NewReader(make([]byte, 128)), 128, nonce, key, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if off, err := r.Seek(10, io.SeekStart); off != 10 || err != nil {
+		t.Errorf("Seek(10, io.SeekStart) returned %d, %v", off, err)
+	}
+	if off, err := r.Seek(10, io.SeekCurrent); off != 20 || err != nil {
+		t.Errorf("Seek(10, io.SeekCurrent) returned %d, %v", off, err)
+	}
+	if off, err := r.Seek(-28, io.SeekEnd); off != 100 || err != nil {
+		t.Errorf("Seek(-28, io.SeekEnd) returned %d, %v", off, err)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); err != ErrOffset {
+		t.Errorf("Seek returned %v - want %v", err, ErrOffset)
+	}
+	if _, err := r.Seek(0, 3); err == nil {
+		t.Error("Seek accepted an invalid whence")
+	}
+	if _, err := r.ReadAt(make([]byte, 1), -1); err != ErrOffset {
+		t.Errorf("ReadAt returned %v - want %v", err, ErrOffset)
+	}
+	if _, err := NewReader(nil, (1<<38)+1, nonce, key, 20); err == nil {
+		t.Error("NewReader accepted a size exceeding the keystream period")
+	}
+
+	// The last block of the keystream period can be decrypted.
+	r, err = NewReader(zeroReader{}, 1<<38, nonce, key, 20)
+	if err != nil {
+		t.Fatalf("NewReader failed for the full keystream period: %v", err)
+	}
+	want := make([]byte, 64)
+	XORKeyStreamAt(want, want, nonce, key, 20, (1<<32)-1)
+	buf := make([]byte, 100)
+	if n, err := r.ReadAt(buf, (1<<38)-64); n != 64 || err != io.EOF {
+		t.Fatalf("ReadAt of the last block returned %d, %v", n, err)
+	}
+	if !bytes.Equal(buf[:64], want) {
+		t.Fatalf("ReadAt of the last block mismatch:\n \t got:  %s\n \t want: %s", toHex(buf[:64]), toHex(want))
+	}
+}
+
+// zeroReader is an io.ReaderAt of infinitely many zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) ReadAt(p []byte, off int64) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -22,13 +22,21 @@ import (
 // - 8 bytes:  ChaCha20 with a 64 bit nonce and a 2^64 * 64 byte period.
 // - 12 bytes: ChaCha20 as defined in RFC 7539 and a 2^32 * 64 byte period.
 // - 24 bytes: XChaCha20 with a 192 bit nonce and a 2^64 * 64 byte period.
-// Src and dst may be the same slice but otherwise should not overlap.
-// If len(dst) < len(src) this function panics.
+// Src and dst may be the same slice but otherwise must not overlap.
+// If len(dst) < len(src) or dst and src overlap inexactly this function panics.
 // If the nonce is neither 64, 96 nor 192 bits long, this function panics.
 func XORKeyStream(dst, src, nonce, key []byte) {
 	chacha.XORKeyStream(dst, src, nonce, key, 20)
 }
 
+// XORKeyStreamChecked behaves like XORKeyStream but returns an error instead
+// of panicking if len(dst) < len(src), dst and src overlap inexactly or the nonce
+// or key length is invalid.
+// The returned errors are the sentinel errors of the chacha package.
+func XORKeyStreamChecked(dst, src, nonce, key []byte) error {
+	return chacha.XORKeyStreamChecked(dst, src, nonce, key, 20)
+}
+
 // NewCipher returns a new cipher.Stream implementing a ChaCha20 version.
 // The nonce must be unique for one key for all time.
 // The length of the nonce determinds the version of ChaCha20:
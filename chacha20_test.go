@@ -73,6 +73,22 @@ func benchmarkXORKeyStream(b *testing.B, size int, nonceSize int) {
 	}
 }
 
+func benchmarkReset(b *testing.B, size int, nonceSize int) {
+	var key [32]byte
+	nonce := make([]byte, nonceSize)
+	c, _ := chacha.NewCipher(nonce, key[:], 20)
+	buf := make([]byte, size)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		nonce[0] = byte(i)
+		c.Reset(nonce, key[:])
+		c.XORKeyStream(buf, buf)
+	}
+}
+
 func BenchmarkChaCha20_64(b *testing.B)              { benchmarkCipher(b, 64, chacha.NonceSize) }
 func BenchmarkChaCha20_1K(b *testing.B)              { benchmarkCipher(b, 1024, chacha.NonceSize) }
 func BenchmarkXChaCha20_64(b *testing.B)             { benchmarkXORKeyStream(b, 64, chacha.XNonceSize) }
@@ -84,6 +100,32 @@ func BenchmarkXChaCha20_XORKeyStream1K(b *testing.B) {
 	benchmarkXORKeyStream(b, 1024, chacha.XNonceSize)
 }
 
+func BenchmarkReset_ChaCha20_64(b *testing.B)     { benchmarkReset(b, 64, chacha.NonceSize) }
+func BenchmarkReset_ChaCha20IETF_64(b *testing.B) { benchmarkReset(b, 64, chacha.INonceSize) }
+func BenchmarkReset_XChaCha20_64(b *testing.B)    { benchmarkReset(b, 64, chacha.XNonceSize) }
+func BenchmarkReset_ChaCha20_1K(b *testing.B)     { benchmarkReset(b, 1024, chacha.NonceSize) }
+func BenchmarkReset_XChaCha20_1K(b *testing.B)    { benchmarkReset(b, 1024, chacha.XNonceSize) }
+
+func TestResetAllocs(t *testing.T) {
+	var key [32]byte
+	buf := make([]byte, 1024)
+	for _, nonceSize := range []int{chacha.NonceSize, chacha.INonceSize, chacha.XNonceSize} {
+		nonce := make([]byte, nonceSize)
+		c, err := chacha.NewCipher(nonce, key[:], 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		allocs := testing.AllocsPerRun(100, func() {
+			nonce[0]++
+			c.Reset(nonce, key[:])
+			c.XORKeyStream(buf, buf)
+		})
+		if allocs != 0 {
+			t.Errorf("NonceSize %d: Reset and XORKeyStream allocated %v times - want 0", nonceSize, allocs)
+		}
+	}
+}
+
 var vectors = []struct {
 	key, nonce, plaintext, ciphertext []byte
 }{
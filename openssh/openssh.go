@@ -0,0 +1,159 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// Package openssh implements the chacha20-poly1305@openssh.com packet
+// cipher of the SSH transport protocol as specified by OpenSSH's
+// PROTOCOL.chacha20poly1305.
+//
+// The cipher uses ChaCha20 with a 64 bit nonce and two 256 bit keys.
+// The nonce is the 64 bit big endian sequence number of the packet.
+// The header key (the second half of the 512 bit key) encrypts the 4 byte
+// packet length, such that the receiver can decrypt the length before
+// receiving the whole packet. The main key (the first half) encrypts the
+// rest of the packet starting at keystream block 1. The Poly1305 key is
+// the first 256 bits of keystream block 0 of the main key. The Poly1305
+// authenticator covers the encrypted length and the encrypted packet
+// and is appended to the packet.
+package openssh // import "github.com/aead/chacha20/openssh"
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+
+	"github.com/aead/chacha20/chacha"
+	"github.com/aead/chacha20/poly1305"
+)
+
+const (
+	// KeySize is the size of the key in bytes - the main
+	// key followed by the header key.
+	KeySize = 2 * chacha.KeySize
+
+	// LengthSize is the size of the packet length in bytes.
+	LengthSize = 4
+
+	// TagSize is the size of the Poly1305 authenticator in bytes.
+	TagSize = poly1305.TagSize
+)
+
+var (
+	errKeySize    = errors.New("openssh: bad key length")
+	errLength     = errors.New("openssh: bad packet length size")
+	errAuthFailed = errors.New("openssh: message authentication failed")
+)
+
+// Cipher en/decrypts SSH packets using chacha20-poly1305@openssh.com.
+type Cipher struct {
+	mainKey   [chacha.KeySize]byte
+	headerKey [chacha.KeySize]byte
+}
+
+// NewCipher returns a new *openssh.Cipher using the given key.
+// The key must be KeySize bytes long - otherwise a non-nil
+// error is returned.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, errKeySize
+	}
+	c := new(Cipher)
+	copy(c.mainKey[:], key[:chacha.KeySize])
+	copy(c.headerKey[:], key[chacha.KeySize:])
+	return c, nil
+}
+
+// EncryptPacket encrypts and authenticates the packet with the given
+// sequence number and appends the result to dst, returning the updated
+// slice. The packet must start with the 4 byte packet length followed by
+// the padding length, the payload and the padding. The returned ciphertext
+// is len(packet) + TagSize bytes long. The packet and dst must not overlap.
+// EncryptPacket panics if the packet is smaller than LengthSize.
+func (c *Cipher) EncryptPacket(dst []byte, seqNum uint32, packet []byte) []byte {
+	if len(packet) < LengthSize {
+		panic("openssh: packet is too short")
+	}
+	var nonce [chacha.NonceSize]byte
+	binary.BigEndian.PutUint64(nonce[:], uint64(seqNum))
+
+	ret, out := sliceForAppend(dst, len(packet)+TagSize)
+	ciphertext, mac := out[:len(packet)], out[len(packet):]
+	chacha.XORKeyStream(ciphertext[:LengthSize], packet[:LengthSize], nonce[:], c.headerKey[:], 20)
+	chacha.XORKeyStreamAt(ciphertext[LengthSize:], packet[LengthSize:], nonce[:], c.mainKey[:], 20, 1)
+
+	var sum [TagSize]byte
+	c.authenticate(&sum, ciphertext, &nonce)
+	copy(mac, sum[:])
+	return ret
+}
+
+// DecryptLength decrypts the 4 byte encrypted packet length of the packet
+// with the given sequence number. The length is not authenticated - so the
+// caller must not act on it before the whole packet has been verified by
+// DecryptPacket - except for reading the packet.
+// If the encrypted length is not LengthSize bytes long a non-nil error
+// is returned.
+func (c *Cipher) DecryptLength(seqNum uint32, encLength []byte) (uint32, error) {
+	if len(encLength) != LengthSize {
+		return 0, errLength
+	}
+	var nonce [chacha.NonceSize]byte
+	binary.BigEndian.PutUint64(nonce[:], uint64(seqNum))
+
+	var length [LengthSize]byte
+	chacha.XORKeyStream(length[:], encLength, nonce[:], c.headerKey[:], 20)
+	return binary.BigEndian.Uint32(length[:]), nil
+}
+
+// DecryptPacket verifies and decrypts the ciphertext produced by EncryptPacket
+// with the given sequence number, appends the packet - including the 4 byte
+// packet length - to dst and returns the updated slice. The packet is only
+// decrypted if the ciphertext is authentic - otherwise a non-nil error is
+// returned. The ciphertext and dst must not overlap.
+func (c *Cipher) DecryptPacket(dst []byte, seqNum uint32, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < LengthSize+TagSize {
+		return nil, errAuthFailed
+	}
+	var nonce [chacha.NonceSize]byte
+	binary.BigEndian.PutUint64(nonce[:], uint64(seqNum))
+
+	mac := ciphertext[len(ciphertext)-TagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-TagSize]
+
+	var sum [TagSize]byte
+	c.authenticate(&sum, ciphertext, &nonce)
+	if subtle.ConstantTimeCompare(sum[:], mac) != 1 {
+		return nil, errAuthFailed
+	}
+
+	ret, packet := sliceForAppend(dst, len(ciphertext))
+	chacha.XORKeyStream(packet[:LengthSize], ciphertext[:LengthSize], nonce[:], c.headerKey[:], 20)
+	chacha.XORKeyStreamAt(packet[LengthSize:], ciphertext[LengthSize:], nonce[:], c.mainKey[:], 20, 1)
+	return ret, nil
+}
+
+// authenticate computes the Poly1305 authenticator of the ciphertext
+// using the first 32 bytes of keystream block 0 of the main key.
+func (c *Cipher) authenticate(mac *[TagSize]byte, ciphertext []byte, nonce *[chacha.NonceSize]byte) {
+	var polyKey [32]byte
+	chacha.KeyStream(polyKey[:], nonce[:], c.mainKey[:], 20)
+	poly1305.Sum(mac, ciphertext, &polyKey)
+	for i := range polyKey {
+		polyKey[i] = 0
+	}
+}
+
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes and a
+// second slice that aliases into it and contains only the extra bytes. If the
+// original slice has sufficient capacity then no allocation is performed.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package openssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func toHex(bits []byte) string {
+	return hex.EncodeToString(bits)
+}
+
+func fromHex(bits string) []byte {
+	b, err := hex.DecodeString(bits)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestVectors(t *testing.T) {
+	for i, v := range vectors {
+		c, err := NewCipher(v.key)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create Cipher: %v", i, err)
+		}
+
+		ciphertext := c.EncryptPacket(nil, v.seqNum, v.packet)
+		if !bytes.Equal(ciphertext, v.ciphertext) {
+			t.Errorf("Test %d: ciphertext mismatch:\n \t got:  %s\n \t want: %s", i, toHex(ciphertext), toHex(v.ciphertext))
+		}
+
+		length, err := c.DecryptLength(v.seqNum, v.ciphertext[:LengthSize])
+		if err != nil {
+			t.Fatalf("Test %d: DecryptLength failed: %v", i, err)
+		}
+		if want := binary.BigEndian.Uint32(v.packet); length != want {
+			t.Errorf("Test %d: packet length mismatch: got %d - want %d", i, length, want)
+		}
+
+		packet, err := c.DecryptPacket(nil, v.seqNum, v.ciphertext)
+		if err != nil {
+			t.Fatalf("Test %d: DecryptPacket failed: %v", i, err)
+		}
+		if !bytes.Equal(packet, v.packet) {
+			t.Errorf("Test %d: packet mismatch:\n \t got:  %s\n \t want: %s", i, toHex(packet), toHex(v.packet))
+		}
+	}
+}
+
+func TestDecryptPacket(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := make([]byte, 4+1+64+7)
+	binary.BigEndian.PutUint32(packet, uint32(len(packet)-LengthSize))
+	packet[4] = 7
+
+	const seqNum = 0xffffffff
+	ciphertext := c.EncryptPacket(nil, seqNum, packet)
+	flip := func(i int) []byte {
+		b := append([]byte(nil), ciphertext...)
+		b[i] ^= 1
+		return b
+	}
+	for i, test := range []struct {
+		seqNum     uint32
+		ciphertext []byte
+	}{
+		{seqNum: seqNum - 1, ciphertext: ciphertext},
+		{seqNum: seqNum, ciphertext: ciphertext[:LengthSize+TagSize-1]},
+		{seqNum: seqNum, ciphertext: ciphertext[:len(ciphertext)-1]},
+		{seqNum: seqNum, ciphertext: flip(0)}, // packet length
+		{seqNum: seqNum, ciphertext: flip(LengthSize)},
+		{seqNum: seqNum, ciphertext: flip(len(ciphertext) - 1)},
+	} {
+		if _, err := c.DecryptPacket(nil, test.seqNum, test.ciphertext); err != errAuthFailed {
+			t.Errorf("Test %d: DecryptPacket returned %v - want %v", i, err, errAuthFailed)
+		}
+	}
+
+	dst := []byte("prefix")
+	out, err := c.DecryptPacket(dst, seqNum, ciphertext)
+	if err != nil {
+		t.Fatalf("DecryptPacket failed: %v", err)
+	}
+	if !bytes.Equal(out[:len(dst)], dst) || !bytes.Equal(out[len(dst):], packet) {
+		t.Errorf("DecryptPacket returned %s - want %s", toHex(out), toHex(append(dst, packet...)))
+	}
+
+	if _, err = c.DecryptLength(seqNum, ciphertext[:LengthSize+1]); err != errLength {
+		t.Errorf("DecryptLength returned %v - want %v", err, errLength)
+	}
+	if _, err = NewCipher(key[:32]); err != errKeySize {
+		t.Errorf("NewCipher returned %v - want %v", err, errKeySize)
+	}
+}
+
+func benchmarkEncryptPacket(b *testing.B, size int) {
+	c, err := NewCipher(make([]byte, KeySize))
+	if err != nil {
+		b.Fatal(err)
+	}
+	packet := make([]byte, size)
+	buf := make([]byte, 0, size+TagSize)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.EncryptPacket(buf, uint32(i), packet)
+	}
+}
+
+func BenchmarkEncryptPacket_64(b *testing.B) { benchmarkEncryptPacket(b, 64) }
+func BenchmarkEncryptPacket_1K(b *testing.B) { benchmarkEncryptPacket(b, 1024) }
+func BenchmarkEncryptPacket_8K(b *testing.B) { benchmarkEncryptPacket(b, 8*1024) }
+
+// The first vectors are client-to-server packets of an OpenSSH 9.2p1 session (a service
+// request, a channel open, 313 bytes of channel data and a disconnect) captured together
+// with the negotiated key. The last vector is generated using libsodium's ChaCha20 and
+// Poly1305 functions and covers all bytes of the sequence number.
+var vectors = []struct {
+	key        []byte
+	seqNum     uint32
+	packet     []byte
+	ciphertext []byte
+}{
+	{
+		key:        traceKey,
+		seqNum:     0,
+		packet:     fromHex("0000001806050000000c7373682d757365726175746829721f3c6523"),
+		ciphertext: fromHex("06262e9c4332e01072133e56ead1dec4063872430a3d65baea0021d4e0511216a5b7145171116949cb55ea4a"),
+	},
+	{
+		key:    traceKey,
+		seqNum: 2,
+		packet: fromHex("00000020075a0000000773657373696f6e000000000020000000008000223ab8c7d9dc74"),
+		ciphertext: fromHex("3d1177884c014a8e6cbbeaf588cce307e2da72a8cd5891e90e24aa708a21c7c7bed9f07f856c06882d3fba147370e257" +
+			"2c8b4d26"),
+	},
+	{
+		key:    traceKey,
+		seqNum: 4,
+		packet: fromHex("00000148055e000000000000013968656c6c6f2c20776f726c640a616161616161616161616161616161616161616161" +
+			"616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161" +
+			"616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161" +
+			"616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161" +
+			"616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161" +
+			"616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161616161" +
+			"616161616161616161616161616161616161616161616161616161616161616161616161616161e9b9d598de"),
+		ciphertext: fromHex("cd646a41aaea82f3ca0d95fbf9f5e883bfa58ac4192bae2bd03642364046c83bd6dbfaeee8d992ded6a24267c1705aea" +
+			"4a9a86d8d3a8f152f891cd83c3d245ba08479a531ee98ea7fb42f44beb3592449e12d29668c14a00ddd8b8fca8ceb743" +
+			"bbdf6fc27927c2744cf2605ebf129fcc24f631f0647e52328c8bb02741e5ce0f40f9417c025d6e60016afa1c6fe6bdb2" +
+			"8be5d003c6677f6b735cf0a8865e522ee7e2caebc4a0c794c39d658bbd25fc0f2074dfd3e330133cd84789e303c821f8" +
+			"d915da04f95e4ff0c8549b203fcb00b7734af0ac2e03c255001385a858a279463ecf112f18415144510a2ec17b021a89" +
+			"15ab575000a567f6827bff3a294e734d4db6e30a0a2b4c4fbd23c3f9fd4c912cdf55224ad3b226465c5f8c50acda1bdd" +
+			"98bab02ca46cf0b5be868b2ba44dbd46a2107a64611b12a95bc0960e723a1aeac2ee7b94864e4d251fede9d419d1e29e" +
+			"d70a97fb3968cf5dd945ef70"),
+	},
+	{
+		key:    traceKey,
+		seqNum: 7,
+		packet: fromHex("0000002806010000000b00000014646973636f6e6e6563746564206279207573657200000000813e9bcdb802"),
+		ciphertext: fromHex("b5d6004339998e2e47b5c3a5ca0c8114e423dad43608b4ba8db23797f9004444aa17ebae3ea0ae8850bc63acccb95231" +
+			"c083e667279a9361016375bf"),
+	},
+	{
+		key: fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f"),
+		seqNum:     0xdeadbeef,
+		packet:     fromHex("00000016045e00000000000000086f70656e73736821a5a5a5a5"),
+		ciphertext: fromHex("081cfb8ba3cbf500facb1cf617ab23cd120f04fd23bc132e4fccbdd6ed4354a0c6f3929b7c79acbd2483"),
+	},
+}
+
+var traceKey = fromHex("363a08e339924be3ce39e9639e9a13d54e75af72952fb2c16061c3b75732b6dc" +
+	"12395d3000126198c588879f9d7875a07a6368d3c4a7f38608251d01c197a15b")
@@ -0,0 +1,13 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build !go1.12
+
+package poly1305
+
+func add64(x, y, carry uint64) (sum, carryOut uint64) { return add64Generic(x, y, carry) }
+
+func sub64(x, y, borrow uint64) (diff, borrowOut uint64) { return sub64Generic(x, y, borrow) }
+
+func mul64(x, y uint64) (hi, lo uint64) { return mul64Generic(x, y) }
@@ -0,0 +1,15 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build go1.12
+
+package poly1305
+
+import "math/bits"
+
+func add64(x, y, carry uint64) (sum, carryOut uint64) { return bits.Add64(x, y, carry) }
+
+func sub64(x, y, borrow uint64) (diff, borrowOut uint64) { return bits.Sub64(x, y, borrow) }
+
+func mul64(x, y uint64) (hi, lo uint64) { return bits.Mul64(x, y) }
@@ -0,0 +1,37 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build go1.12
+
+package poly1305
+
+import (
+	"math/bits"
+	"testing"
+)
+
+func TestBitsGeneric(t *testing.T) {
+	values := []uint64{
+		0, 1, 2, 3, 1<<32 - 1, 1 << 32, 1<<32 + 1, 1<<63 - 1, 1 << 63, 1<<63 + 1,
+		1<<64 - 2, 1<<64 - 1, rMask0, rMask1, 0x0123456789abcdef, 0xfedcba9876543210,
+	}
+	for _, x := range values {
+		for _, y := range values {
+			for c := uint64(0); c < 2; c++ {
+				s, co := add64Generic(x, y, c)
+				if ws, wco := bits.Add64(x, y, c); s != ws || co != wco {
+					t.Fatalf("add64Generic(%x, %x, %d) = %x, %d - want %x, %d", x, y, c, s, co, ws, wco)
+				}
+				d, bo := sub64Generic(x, y, c)
+				if wd, wbo := bits.Sub64(x, y, c); d != wd || bo != wbo {
+					t.Fatalf("sub64Generic(%x, %x, %d) = %x, %d - want %x, %d", x, y, c, d, bo, wd, wbo)
+				}
+			}
+			hi, lo := mul64Generic(x, y)
+			if whi, wlo := bits.Mul64(x, y); hi != whi || lo != wlo {
+				t.Fatalf("mul64Generic(%x, %x) = %x, %x - want %x, %x", x, y, hi, lo, whi, wlo)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// Package poly1305 implements Poly1305 one-time message authentication code
+// as specified in RFC 8439.
+//
+// Poly1305 is a fast, one-time authentication function. It is infeasible for an
+// attacker to generate an authenticator for a message without the key.
+// However, a key must only be used for a single message. Authenticating two
+// different messages with the same key allows an attacker to forge
+// authenticators for other messages with the same key.
+package poly1305 // import "github.com/aead/chacha20/poly1305"
+
+import "crypto/subtle"
+
+// TagSize is the size of the Poly1305 authenticator in bytes.
+const TagSize = 16
+
+var (
+	useBMI2 bool
+)
+
+// macState holds the accumulator h, the clamped key r and the
+// key s. The assembly implementations depend on this layout.
+type macState struct {
+	h [3]uint64
+	r [2]uint64
+	s [2]uint64
+}
+
+// Sum generates an authenticator for msg using a one-time key and puts the
+// 16-byte result into out. Authenticating two different messages with the same
+// key allows an attacker to forge messages at will.
+func Sum(out *[TagSize]byte, msg []byte, key *[32]byte) {
+	h := New(key)
+	h.Write(msg)
+	h.Sum(out[:0])
+}
+
+// Verify returns true if mac is a valid authenticator for msg with the given
+// key. The comparison is done in constant time.
+func Verify(mac *[TagSize]byte, msg []byte, key *[32]byte) bool {
+	var sum [TagSize]byte
+	Sum(&sum, msg, key)
+	return subtle.ConstantTimeCompare(sum[:], mac[:]) == 1
+}
+
+// Hash implements the Poly1305 MAC as a streaming hash.
+// It must be used to authenticate only one message.
+type Hash struct {
+	state macState
+	buf   [TagSize]byte
+	off   int
+}
+
+// New returns a Hash computing the Poly1305 authenticator
+// of the written data using the one-time key.
+func New(key *[32]byte) *Hash {
+	h := new(Hash)
+	initialize(&(h.state), key)
+	return h
+}
+
+// Size returns the number of bytes Sum will append.
+func (h *Hash) Size() int { return TagSize }
+
+// Write adds more data to the running Poly1305 hash.
+// It never returns an error.
+func (h *Hash) Write(p []byte) (n int, err error) {
+	n = len(p)
+	if h.off > 0 {
+		dif := copy(h.buf[h.off:], p)
+		p = p[dif:]
+		h.off += dif
+		if h.off < TagSize {
+			return
+		}
+		update(&(h.state), h.buf[:])
+		h.off = 0
+	}
+
+	if nn := len(p) &^ (TagSize - 1); nn > 0 {
+		update(&(h.state), p[:nn])
+		p = p[nn:]
+	}
+
+	if len(p) > 0 {
+		h.off += copy(h.buf[:], p)
+	}
+	return
+}
+
+// Sum appends the Poly1305 authenticator of the data written so far to b
+// and returns the resulting slice. It does not change the underlying hash
+// state.
+func (h *Hash) Sum(b []byte) []byte {
+	var out [TagSize]byte
+	state := h.state
+	if h.off > 0 {
+		updateGeneric(&state, h.buf[:h.off])
+	}
+	finalize(&out, &state)
+	return append(b, out[:]...)
+}
+
+// Verify returns true if expected is a valid authenticator for the data
+// written so far. The comparison is done in constant time.
+func (h *Hash) Verify(expected []byte) bool {
+	var sum [TagSize]byte
+	h.Sum(sum[:0])
+	return subtle.ConstantTimeCompare(sum[:], expected) == 1
+}
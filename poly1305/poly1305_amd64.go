@@ -0,0 +1,35 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build amd64,!gccgo,!appengine,!nacl
+
+package poly1305
+
+import "golang.org/x/sys/cpu"
+
+func init() {
+	useBMI2 = cpu.X86.HasBMI2
+}
+
+// This function is implemented in poly1305_amd64.s
+//go:noescape
+func updateAMD64(state *macState, msg []byte)
+
+// This function is implemented in poly1305_amd64.s
+//go:noescape
+func updateBMI2(state *macState, msg []byte)
+
+func update(state *macState, msg []byte) {
+	if n := len(msg) &^ (TagSize - 1); n > 0 {
+		if useBMI2 {
+			updateBMI2(state, msg[:n])
+		} else {
+			updateAMD64(state, msg[:n])
+		}
+		msg = msg[n:]
+	}
+	if len(msg) > 0 {
+		updateGeneric(state, msg)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build amd64,!gccgo,!appengine,!nacl
+
+#include "textflag.h"
+
+// POLY1305_ADD adds the 16 byte block at msg (padded with
+// a 1 bit) to the accumulator h0, h1, h2.
+#define POLY1305_ADD(msg, h0, h1, h2) \
+	ADDQ 0(msg), h0; \
+	ADCQ 8(msg), h1; \
+	ADCQ $1, h2;     \
+	LEAQ 16(msg), msg
+
+// POLY1305_REDUCE reduces the 256 bit product t0, t1, t2, t3
+// modulo 2^130 - 5 and writes the result to h0, h1, h2.
+// The bits above 2^130 are multiplied by 5 = 4 + 1 and
+// added to the lower 130 bits. t0, t2 and t3 are modified.
+#define POLY1305_REDUCE(h0, h1, h2, t0, t1, t2, t3) \
+	MOVQ t0, h0;                   \
+	MOVQ t1, h1;                   \
+	MOVQ t2, h2;                   \
+	ANDQ $3, h2;                   \
+	MOVQ t2, t0;                   \
+	ANDQ $0xFFFFFFFFFFFFFFFC, t0;  \
+	ADDQ t0, h0;                   \
+	ADCQ t3, h1;                   \
+	ADCQ $0, h2;                   \
+	SHRQ $2, t3, t2;               \
+	SHRQ $2, t3;                   \
+	ADDQ t2, h0;                   \
+	ADCQ t3, h1;                   \
+	ADCQ $0, h2
+
+// POLY1305_MUL multiplies the accumulator h0, h1, h2 with the
+// clamped key r0, r1 using MULQ and stores the 256 bit product
+// in t0, t1, t2, t3. AX and DX are used as temp. registers and
+// h0 is modified.
+#define POLY1305_MUL(h0, h1, h2, r0, r1, t0, t1, t2, t3) \
+	MOVQ  r0, AX;  \
+	MULQ  h0;      \
+	MOVQ  AX, t0;  \
+	MOVQ  DX, t1;  \
+	MOVQ  r0, AX;  \
+	MULQ  h1;      \
+	ADDQ  AX, t1;  \
+	ADCQ  $0, DX;  \
+	MOVQ  r0, t2;  \
+	IMULQ h2, t2;  \
+	ADDQ  DX, t2;  \
+	MOVQ  r1, AX;  \
+	MULQ  h0;      \
+	ADDQ  AX, t1;  \
+	ADCQ  $0, DX;  \
+	MOVQ  DX, h0;  \
+	MOVQ  r1, t3;  \
+	IMULQ h2, t3;  \
+	MOVQ  r1, AX;  \
+	MULQ  h1;      \
+	ADDQ  AX, t2;  \
+	ADCQ  DX, t3;  \
+	ADDQ  h0, t2;  \
+	ADCQ  $0, t3
+
+// POLY1305_MUL_BMI2 is like POLY1305_MUL but uses the BMI2
+// instruction MULX which does not modify the flags.
+// AX and DX are used as temp. registers and h0 is modified.
+#define POLY1305_MUL_BMI2(h0, h1, h2, r0, r1, t0, t1, t2, t3) \
+	MOVQ  r0, DX;     \
+	MULXQ h0, t0, t1; \
+	MULXQ h1, AX, t2; \
+	ADDQ  AX, t1;     \
+	ADCQ  $0, t2;     \
+	IMULQ h2, DX;     \
+	ADDQ  DX, t2;     \
+	MOVQ  r1, DX;     \
+	MULXQ h0, AX, h0; \
+	ADDQ  AX, t1;     \
+	ADCQ  $0, h0;     \
+	MULXQ h1, AX, t3; \
+	IMULQ h2, DX;     \
+	ADDQ  AX, t2;     \
+	ADCQ  DX, t3;     \
+	ADDQ  h0, t2;     \
+	ADCQ  $0, t3
+
+#define State DI
+#define Msg SI
+#define Len R15
+#define H0 R8
+#define H1 R9
+#define H2 R10
+#define R0 R11
+#define R1 R12
+#define T0 BX
+#define T1 CX
+#define T2 R13
+#define T3 R14
+
+// func updateAMD64(state *macState, msg []byte)
+TEXT ·updateAMD64(SB), NOSPLIT, $0-32
+	MOVQ state+0(FP), State
+	MOVQ msg_base+8(FP), Msg
+	MOVQ msg_len+16(FP), Len
+
+	MOVQ 0(State), H0
+	MOVQ 8(State), H1
+	MOVQ 16(State), H2
+	MOVQ 24(State), R0
+	MOVQ 32(State), R1
+
+	CMPQ Len, $16
+	JB   DONE
+
+LOOP:
+	POLY1305_ADD(Msg, H0, H1, H2)
+	POLY1305_MUL(H0, H1, H2, R0, R1, T0, T1, T2, T3)
+	POLY1305_REDUCE(H0, H1, H2, T0, T1, T2, T3)
+	SUBQ $16, Len
+	CMPQ Len, $16
+	JAE  LOOP
+
+DONE:
+	MOVQ H0, 0(State)
+	MOVQ H1, 8(State)
+	MOVQ H2, 16(State)
+	RET
+
+// func updateBMI2(state *macState, msg []byte)
+TEXT ·updateBMI2(SB), NOSPLIT, $0-32
+	MOVQ state+0(FP), State
+	MOVQ msg_base+8(FP), Msg
+	MOVQ msg_len+16(FP), Len
+
+	MOVQ 0(State), H0
+	MOVQ 8(State), H1
+	MOVQ 16(State), H2
+	MOVQ 24(State), R0
+	MOVQ 32(State), R1
+
+	CMPQ Len, $16
+	JB   DONE
+
+LOOP:
+	POLY1305_ADD(Msg, H0, H1, H2)
+	POLY1305_MUL_BMI2(H0, H1, H2, R0, R1, T0, T1, T2, T3)
+	POLY1305_REDUCE(H0, H1, H2, T0, T1, T2, T3)
+	SUBQ $16, Len
+	CMPQ Len, $16
+	JAE  LOOP
+
+DONE:
+	MOVQ H0, 0(State)
+	MOVQ H1, 8(State)
+	MOVQ H2, 16(State)
+	RET
+
+#undef State
+#undef Msg
+#undef Len
+#undef H0
+#undef H1
+#undef H2
+#undef R0
+#undef R1
+#undef T0
+#undef T1
+#undef T2
+#undef T3
@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package poly1305
+
+import "encoding/binary"
+
+const (
+	rMask0 = 0x0FFFFFFC0FFFFFFF
+	rMask1 = 0x0FFFFFFC0FFFFFFC
+
+	p0 = 0xFFFFFFFFFFFFFFFB // The prime 2^130 - 5 as 3 64 bit limbs
+	p1 = 0xFFFFFFFFFFFFFFFF
+	p2 = 0x0000000000000003
+)
+
+func initialize(state *macState, key *[32]byte) {
+	state.h = [3]uint64{}
+	state.r[0] = binary.LittleEndian.Uint64(key[0:]) & rMask0
+	state.r[1] = binary.LittleEndian.Uint64(key[8:]) & rMask1
+	state.s[0] = binary.LittleEndian.Uint64(key[16:])
+	state.s[1] = binary.LittleEndian.Uint64(key[24:])
+}
+
+// updateGeneric absorbs msg into the accumulator. All 16 byte
+// blocks are padded with a 1 bit - a trailing partial block
+// is padded with a 1 bit followed by zeros as specified
+// in RFC 8439.
+func updateGeneric(state *macState, msg []byte) {
+	h0, h1, h2 := state.h[0], state.h[1], state.h[2]
+	r0, r1 := state.r[0], state.r[1]
+
+	for len(msg) > 0 {
+		var c uint64
+		if len(msg) >= TagSize {
+			h0, c = add64(h0, binary.LittleEndian.Uint64(msg[0:]), 0)
+			h1, c = add64(h1, binary.LittleEndian.Uint64(msg[8:]), c)
+			h2 += c + 1
+			msg = msg[TagSize:]
+		} else {
+			var block [TagSize]byte
+			block[copy(block[:], msg)] = 1
+			h0, c = add64(h0, binary.LittleEndian.Uint64(block[0:]), 0)
+			h1, c = add64(h1, binary.LittleEndian.Uint64(block[8:]), c)
+			h2 += c
+			msg = nil
+		}
+
+		// h * r - h2 is at most 7 and r0, r1 are clamped,
+		// so h2*r0 and h2*r1 fit into 64 bits.
+		h0r0Hi, h0r0Lo := mul64(h0, r0)
+		h1r0Hi, h1r0Lo := mul64(h1, r0)
+		h0r1Hi, h0r1Lo := mul64(h0, r1)
+		h1r1Hi, h1r1Lo := mul64(h1, r1)
+		h2r0 := h2 * r0
+		h2r1 := h2 * r1
+
+		t0 := h0r0Lo
+		t1, c := add64(h0r0Hi, h1r0Lo, 0)
+		t2, _ := add64(h1r0Hi, h2r0, c)
+		t1, c = add64(t1, h0r1Lo, 0)
+		t2, c = add64(t2, h0r1Hi, c)
+		t3 := h2r1 + c
+		t2, c = add64(t2, h1r1Lo, 0)
+		t3 += h1r1Hi + c
+
+		// reduce modulo 2^130 - 5: the bits above 2^130 (m) are
+		// multiplied by 5 = 4 + 1 and added to the lower 130 bits.
+		h0, h1, h2 = t0, t1, t2&3
+		m0, m1 := t2&^3, t3
+		h0, c = add64(h0, m0, 0)
+		h1, c = add64(h1, m1, c)
+		h2 += c
+		m0, m1 = (m0>>2)|(m1<<62), m1>>2
+		h0, c = add64(h0, m0, 0)
+		h1, c = add64(h1, m1, c)
+		h2 += c
+	}
+	state.h[0], state.h[1], state.h[2] = h0, h1, h2
+}
+
+func finalize(out *[TagSize]byte, state *macState) {
+	h0, h1, h2 := state.h[0], state.h[1], state.h[2]
+
+	// compute h - p and select it in constant time if h >= p
+	t0, b := sub64(h0, p0, 0)
+	t1, b := sub64(h1, p1, b)
+	_, b = sub64(h2, p2, b)
+
+	mask := b - 1 // all ones if h >= p
+	h0 = (h0 &^ mask) | (t0 & mask)
+	h1 = (h1 &^ mask) | (t1 & mask)
+
+	var c uint64
+	h0, c = add64(h0, state.s[0], 0)
+	h1, _ = add64(h1, state.s[1], c)
+
+	binary.LittleEndian.PutUint64(out[0:], h0)
+	binary.LittleEndian.PutUint64(out[8:], h1)
+}
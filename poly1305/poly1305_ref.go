@@ -0,0 +1,15 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build !amd64 gccgo appengine nacl
+
+package poly1305
+
+func init() {
+	useBMI2 = false
+}
+
+func update(state *macState, msg []byte) {
+	updateGeneric(state, msg)
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package poly1305
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func toHex(bits []byte) string {
+	return hex.EncodeToString(bits)
+}
+
+func fromHex(bits string) []byte {
+	b, err := hex.DecodeString(bits)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestVectors(t *testing.T) {
+	defer func(bmi2 bool) {
+		useBMI2 = bmi2
+	}(useBMI2)
+
+	if useBMI2 {
+		t.Log("BMI2 version")
+		testVectors(t)
+		useBMI2 = false
+	}
+	t.Log("default version")
+	testVectors(t)
+}
+
+func TestGeneric(t *testing.T) {
+	for i, v := range vectors {
+		var key [32]byte
+		copy(key[:], v.key)
+
+		var state macState
+		var tag [TagSize]byte
+		initialize(&state, &key)
+		updateGeneric(&state, v.msg)
+		finalize(&tag, &state)
+		if !bytes.Equal(tag[:], v.tag) {
+			t.Errorf("Test %d: tag mismatch:\n \t got:  %s\n \t want: %s", i, toHex(tag[:]), toHex(v.tag))
+		}
+	}
+}
+
+func TestIncremental(t *testing.T) {
+	defer func(bmi2 bool) {
+		useBMI2 = bmi2
+	}(useBMI2)
+
+	if useBMI2 {
+		t.Log("BMI2 version")
+		testIncremental(t, 1025)
+		useBMI2 = false
+	}
+	t.Log("default version")
+	testIncremental(t, 1025)
+}
+
+func testVectors(t *testing.T) {
+	for i, v := range vectors {
+		var key [32]byte
+		var tag [TagSize]byte
+		copy(key[:], v.key)
+		copy(tag[:], v.tag)
+
+		var sum [TagSize]byte
+		Sum(&sum, v.msg, &key)
+		if !bytes.Equal(sum[:], v.tag) {
+			t.Errorf("Test %d: tag mismatch:\n \t got:  %s\n \t want: %s", i, toHex(sum[:]), toHex(v.tag))
+		}
+		if !Verify(&tag, v.msg, &key) {
+			t.Errorf("Test %d: Verify failed", i)
+		}
+
+		h := New(&key)
+		for j := range v.msg {
+			h.Write(v.msg[j : j+1])
+		}
+		if !h.Verify(v.tag) {
+			t.Errorf("Test %d: Verify failed for byte-wise writes: got %s", i, toHex(h.Sum(nil)))
+		}
+
+		tag[0] ^= 1
+		if Verify(&tag, v.msg, &key) {
+			t.Errorf("Test %d: Verify accepted a modified tag", i)
+		}
+	}
+}
+
+func testIncremental(t *testing.T, size int) {
+	var key [32]byte
+	msg := make([]byte, size)
+	for i := range key {
+		key[i] = byte(i * 7)
+	}
+	for i := range msg {
+		msg[i] = byte(0xff - i)
+	}
+
+	for i := 0; i <= len(msg); i++ {
+		var state macState
+		var ref [TagSize]byte
+		initialize(&state, &key)
+		updateGeneric(&state, msg[:i])
+		finalize(&ref, &state)
+
+		h := New(&key)
+		h.Write(msg[:i/3])
+		h.Write(msg[i/3 : i/2])
+		h.Write(msg[i/2 : i])
+		if sum := h.Sum(nil); !bytes.Equal(sum, ref[:]) {
+			t.Fatalf("Message length %d: tag mismatch:\n \t got:  %s\n \t want: %s", i, toHex(sum), toHex(ref[:]))
+		}
+		key[i%32] = ref[i%TagSize]
+	}
+}
+
+func benchmarkSum(b *testing.B, size int) {
+	var key [32]byte
+	var out [TagSize]byte
+	msg := make([]byte, size)
+
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Sum(&out, msg, &key)
+	}
+}
+
+func benchmarkWrite(b *testing.B, size int) {
+	var key [32]byte
+	h := New(&key)
+	msg := make([]byte, size)
+
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Write(msg)
+	}
+}
+
+func BenchmarkSum_64(b *testing.B)   { benchmarkSum(b, 64) }
+func BenchmarkSum_1K(b *testing.B)   { benchmarkSum(b, 1024) }
+func BenchmarkWrite_64(b *testing.B) { benchmarkWrite(b, 64) }
+func BenchmarkWrite_1K(b *testing.B) { benchmarkWrite(b, 1024) }
+
+var vectors = []struct {
+	key, msg, tag []byte
+}{
+	{ // RFC 8439 - 2.5.2
+		fromHex("85d6be7857556d337f4452fe42d506a80103808afb0db2fd4abff6af4149f51b"),
+		[]byte("Cryptographic Forum Research Group"),
+		fromHex("a8061dc1305136c6c22b8baf0c0127a9"),
+	},
+	{
+		fromHex("746869732069732033322d62797465206b657920666f7220506f6c7931333035"),
+		fromHex("48656c6c6f20776f726c6421"),
+		fromHex("a6f745008f81c916a20dcc74eef2b2f0"),
+	},
+	{
+		fromHex("746869732069732033322d62797465206b657920666f7220506f6c7931333035"),
+		fromHex("0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("49ec78090e481ec6c26b33b91ccc0307"),
+	},
+	{
+		fromHex("3b3a29e93b213a5c5c3b3b053a3a8c0d00000000000000000000000000000000"),
+		fromHex("81d8b2e46a25213b58fee4213a2a28e921c12a9632516d3b73272727becf2129"),
+		fromHex("6dc18b8c344cd79927118bbe84b7f314"),
+	},
+	{ // (2^130 - 1) % (2^130 - 5)
+		fromHex("0100000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("ffffffffffffffffffffffffffffffff0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("04000000000000000000000000000000"),
+	},
+	{ // (2^130 - 6) % (2^130 - 5)
+		fromHex("0100000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("faffffffffffffffffffffffffffffff0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("faffffffffffffffffffffffffffffff"),
+	},
+	{ // (2^130 - 5) % (2^130 - 5)
+		fromHex("0100000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("fbffffffffffffffffffffffffffffff0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("00000000000000000000000000000000"),
+	},
+	{ // (2 * (2^130 - 6)) % (2^130 - 5)
+		fromHex("0100000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("fafffffffffffffffffffffffffffffffaffffffffffffffffffffffffffffff" +
+			"0000000000000000000000000000000000000000000000000000000000000000" +
+			"0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("f9ffffffffffffffffffffffffffffff"),
+	},
+	{ // (2 * (2^130 - 5)) % (2^130 - 5)
+		fromHex("0100000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("fbfffffffffffffffffffffffffffffffbffffffffffffffffffffffffffffff" +
+			"0000000000000000000000000000000000000000000000000000000000000000" +
+			"0000000000000000000000000000000000000000000000000000000000000000"),
+		fromHex("00000000000000000000000000000000"),
+	},
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// Package rand implements a pseudo-random generator based on ChaCha.
+//
+// A Rand generated by New produces the ChaCha/r keystream of the seed
+// and can be used for reproducible simulations. NewFastKeyErasure and
+// NewSecure return a Rand using fast-key-erasure: The first 32 bytes of
+// every generated batch of keystream replace the key and the output is
+// erased from memory once it has been returned. So a compromise of the
+// generator state doesn't reveal previously generated values.
+//
+// A Rand implements io.Reader and - through its Uint64 method - the
+// Source interface of math/rand/v2.
+//
+// Generators returned by New can be split into independent, reproducible
+// sub-streams for parallel jobs using Jump and Split.
+package rand // import "github.com/aead/chacha20/rand"
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math"
+
+	"github.com/aead/chacha20/chacha"
+)
+
+// SeedSize is the size of the seed in bytes.
+const SeedSize = chacha.KeySize
+
+// bufSize is the number of keystream bytes generated at once.
+// 512 bytes are processed by the AVX2 implementation in one batch.
+const bufSize = 512
+
+var zeroNonce [chacha.NonceSize]byte
+
+// Rand is a ChaCha-based pseudo-random generator.
+// A Rand is not safe for concurrent use.
+type Rand struct {
+	cipher *chacha.Cipher
+	key    [32]byte // only set if erase is false
+	rounds int
+	erase  bool
+	child  bool // true if r has been returned by Split
+
+	buf [bufSize]byte
+	off int
+}
+
+// New returns a new Rand producing the ChaCha/r (r = 8, 12 or 20) keystream
+// for the given 256 bit seed and a zero nonce. Two generators returned by New
+// produce the same output for the same seed and number of rounds.
+// If the seed is not 256 bits long or the number of rounds is invalid,
+// a non-nil error is returned.
+func New(seed []byte, rounds int) (*Rand, error) {
+	c, err := chacha.NewCipher(zeroNonce[:], seed, rounds)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rand{cipher: c, rounds: rounds, off: bufSize}
+	copy(r.key[:], seed)
+	return r, nil
+}
+
+// NewFastKeyErasure returns a new Rand using ChaCha/r (r = 8, 12 or 20)
+// with fast-key-erasure. The first 32 bytes of every 512 byte batch of
+// keystream become the new key and the returned output is overwritten
+// with zeros. The output is reproducible for the same seed and number
+// of rounds but differs from the output of New.
+// If the seed is not 256 bits long or the number of rounds is invalid,
+// a non-nil error is returned.
+func NewFastKeyErasure(seed []byte, rounds int) (*Rand, error) {
+	r, err := New(seed, rounds)
+	if err != nil {
+		return nil, err
+	}
+	r.erase = true
+	for i := range r.key {
+		r.key[i] = 0
+	}
+	return r, nil
+}
+
+// NewSecure returns a new Rand using ChaCha/r (r = 8, 12 or 20) with
+// fast-key-erasure seeded from crypto/rand. It returns a non-nil error
+// if the number of rounds is invalid or reading the seed fails.
+func NewSecure(rounds int) (*Rand, error) {
+	var seed [SeedSize]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	r, err := NewFastKeyErasure(seed[:], rounds)
+	for i := range seed {
+		seed[i] = 0
+	}
+	return r, err
+}
+
+// Read fills p with pseudo-random bytes.
+// It always returns len(p) and a nil error.
+func (r *Rand) Read(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if r.off == bufSize {
+			if !r.erase && len(p) >= bufSize {
+				// generate the keystream directly into p
+				k := len(p) &^ (bufSize - 1)
+				r.cipher.KeyStream(p[:k])
+				p = p[k:]
+				continue
+			}
+			r.refill()
+		}
+		k := copy(p, r.buf[r.off:])
+		r.consume(k)
+		p = p[k:]
+	}
+	return n, nil
+}
+
+// Uint64 returns a pseudo-random 64 bit value.
+// It implements the Source interface of math/rand/v2.
+func (r *Rand) Uint64() uint64 {
+	if r.off+8 > bufSize {
+		r.refill()
+	}
+	v := binary.LittleEndian.Uint64(r.buf[r.off:])
+	r.consume(8)
+	return v
+}
+
+// Jump discards the buffered output and skips the next nBlocks 64 byte
+// blocks of the keystream. Therefore two copies of a generator, one of them
+// advanced by Jump(n), produce non-overlapping output as long as the other
+// one doesn't produce more than n * 64 bytes.
+// Jump panics if the generator uses fast-key-erasure or the keystream
+// position would exceed 2^64 blocks.
+func (r *Rand) Jump(nBlocks uint64) {
+	if r.erase {
+		panic("rand: Jump is not supported with fast-key-erasure")
+	}
+	ctr := r.cipher.Counter()
+	if ctr+nBlocks < ctr {
+		panic(chacha.ErrCounterOverflow)
+	}
+	r.cipher.SetCounter(ctr + nBlocks)
+	r.off = bufSize
+}
+
+// Split returns the i-th child generator of r. The child produces the ChaCha/r
+// keystream of the key of r and the 64 bit nonce i + 1 while r uses the zero
+// nonce. So r and all of its children use the same key but different nonces
+// and no two of them ever generate a keystream block for the same nonce and
+// counter: Their streams never overlap - regardless of how much output each
+// generator produces. Split doesn't change the state of r. Split is
+// reproducible: The i-th child of generators with the same seed produces
+// the same output. Use Jump to partition the stream of a child further.
+// Split panics if i is 2^64 - 1, if r has been returned by Split or if r
+// uses fast-key-erasure.
+func (r *Rand) Split(i uint64) *Rand {
+	if r.erase {
+		panic("rand: Split is not supported with fast-key-erasure")
+	}
+	if r.child {
+		panic("rand: Split is not supported for child generators")
+	}
+	if i == math.MaxUint64 {
+		panic("rand: Split index is too large")
+	}
+	var nonce [chacha.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[:], i+1)
+
+	c, err := chacha.NewCipher(nonce[:], r.key[:], r.rounds)
+	if err != nil {
+		panic(err) // unreachable - the key and rounds are valid
+	}
+	return &Rand{cipher: c, key: r.key, rounds: r.rounds, child: true, off: bufSize}
+}
+
+// refill generates the next batch of keystream. For fast-key-erasure
+// the first 32 bytes of the batch replace the key of the cipher in place.
+func (r *Rand) refill() {
+	r.cipher.KeyStream(r.buf[:])
+	r.off = 0
+	if r.erase {
+		if err := r.cipher.Reset(zeroNonce[:], r.buf[:SeedSize]); err != nil {
+			panic(err) // unreachable - the key is valid and the cipher is not wiped
+		}
+		r.consume(SeedSize)
+	}
+}
+
+// consume marks the next n bytes of the buffer as used. For
+// fast-key-erasure these bytes are overwritten with zeros.
+func (r *Rand) consume(n int) {
+	if r.erase {
+		b := r.buf[r.off : r.off+n]
+		for i := range b {
+			b[i] = 0
+		}
+	}
+	r.off += n
+}
@@ -0,0 +1,281 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package rand
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/aead/chacha20/chacha"
+)
+
+func toHex(bits []byte) string {
+	return hex.EncodeToString(bits)
+}
+
+func fromHex(bits string) []byte {
+	b, err := hex.DecodeString(bits)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestNew(t *testing.T) {
+	seed := make([]byte, SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	for _, rounds := range []int{8, 12, 20} {
+		ref := make([]byte, 4*bufSize)
+		chacha.KeyStream(ref, zeroNonce[:], seed, rounds)
+
+		r, err := New(seed, rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(ref))
+		for i, size := 0, 1; i < len(out); i, size = i+size, size+7 {
+			if i+size > len(out) {
+				size = len(out) - i
+			}
+			if n, err := r.Read(out[i : i+size]); n != size || err != nil {
+				t.Fatalf("Rounds %d: Read returned %d, %v", rounds, n, err)
+			}
+		}
+		if !bytes.Equal(out, ref) {
+			t.Fatalf("Rounds %d: Read mismatch:\n \t got:  %s\n \t want: %s", rounds, toHex(out), toHex(ref))
+		}
+
+		r, err = New(seed, rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(ref); i += 8 {
+			if v, want := r.Uint64(), binary.LittleEndian.Uint64(ref[i:]); v != want {
+				t.Fatalf("Rounds %d: Uint64 #%d returned %x - want %x", rounds, i/8, v, want)
+			}
+		}
+	}
+
+	if _, err := New(seed[:16], 20); err != chacha.ErrKeySize {
+		t.Errorf("New returned %v - want %v", err, chacha.ErrKeySize)
+	}
+	if _, err := New(seed, 10); err != chacha.ErrRounds {
+		t.Errorf("New returned %v - want %v", err, chacha.ErrRounds)
+	}
+}
+
+func TestFastKeyErasure(t *testing.T) {
+	seed := make([]byte, SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	// Compute the expected output of three batches.
+	var ref []byte
+	key := append([]byte(nil), seed...)
+	for i := 0; i < 3; i++ {
+		batch := make([]byte, bufSize)
+		chacha.KeyStream(batch, zeroNonce[:], key, 8)
+		key = batch[:SeedSize]
+		ref = append(ref, batch[SeedSize:]...)
+	}
+
+	r, err := NewFastKeyErasure(seed, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, len(ref))
+	r.Read(out[:100])
+	r.Read(out[100:1000])
+	r.Read(out[1000:])
+	if !bytes.Equal(out, ref) {
+		t.Fatalf("Read mismatch:\n \t got:  %s\n \t want: %s", toHex(out), toHex(ref))
+	}
+
+	r, err = NewFastKeyErasure(seed, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Uint64()
+	for i, v := range r.buf[:SeedSize+8] {
+		if v != 0 {
+			t.Fatalf("Key or output at %d has not been erased", i)
+		}
+	}
+	if v := r.Uint64(); v != binary.LittleEndian.Uint64(ref[8:]) {
+		t.Fatalf("Uint64 returned %x - want %x", v, binary.LittleEndian.Uint64(ref[8:]))
+	}
+
+	// Rekeying must not allocate a new cipher.
+	if n := testing.AllocsPerRun(10, func() { r.Read(out) }); n != 0 {
+		t.Errorf("Read with fast-key-erasure allocated %v times - want 0", n)
+	}
+}
+
+func TestNewSecure(t *testing.T) {
+	r0, err := NewSecure(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, err := NewSecure(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r0.Uint64() == r1.Uint64() && r0.Uint64() == r1.Uint64() {
+		t.Fatal("Two generators produced the same output")
+	}
+}
+
+func TestJump(t *testing.T) {
+	seed := make([]byte, SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	ref := make([]byte, 64*64)
+	chacha.KeyStream(ref, zeroNonce[:], seed, 8)
+
+	r, err := New(seed, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, 64)
+	r.Read(out[:10]) // generates 8 blocks - the remaining output is discarded by Jump
+	r.Jump(4)
+	r.Read(out)
+	if !bytes.Equal(out, ref[12*64:13*64]) {
+		t.Fatalf("Jump mismatch:\n \t got:  %s\n \t want: %s", toHex(out), toHex(ref[12*64:13*64]))
+	}
+
+	// The SIMD implementations are compared to the generic
+	// one by TestSetCounterKeyStream of the chacha package.
+	for i, v := range jumpVectors {
+		r, err := New(seed, v.rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Jump(v.blocks)
+		out := make([]byte, len(v.output))
+		r.Read(out)
+		if !bytes.Equal(out, v.output) {
+			t.Errorf("Test %d: Jump(%d) mismatch:\n \t got:  %s\n \t want: %s", i, v.blocks, toHex(out), toHex(v.output))
+		}
+	}
+
+	fke, err := NewFastKeyErasure(seed, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("Jump succeeded for a fast-key-erasure generator")
+		}
+	}()
+	fke.Jump(1)
+}
+
+func TestSplit(t *testing.T) {
+	seed := make([]byte, SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+
+	for i, v := range splitVectors {
+		r, err := New(seed, v.rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(v.output))
+		r.Split(v.index).Read(out)
+		if !bytes.Equal(out, v.output) {
+			t.Errorf("Test %d: Split(%d) mismatch:\n \t got:  %s\n \t want: %s", i, v.index, toHex(out), toHex(v.output))
+		}
+
+		// The i-th child produces the keystream of the nonce i + 1.
+		nonce := make([]byte, chacha.NonceSize)
+		binary.LittleEndian.PutUint64(nonce, v.index+1)
+		ref := make([]byte, len(v.output))
+		chacha.KeyStream(ref, nonce, seed, v.rounds)
+		if !bytes.Equal(out, ref) {
+			t.Errorf("Test %d: Split(%d) doesn't match the keystream of the nonce %d", i, v.index, v.index+1)
+		}
+	}
+
+	r, err := New(seed, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, out := make([]byte, 128), make([]byte, 128)
+	r.Split(0).Read(ref)
+	r.Read(make([]byte, 100)) // Split must not depend on the state of the parent
+	r.Split(0).Read(out)
+	if !bytes.Equal(out, ref) {
+		t.Fatal("Split is not reproducible")
+	}
+	r.Split(1).Read(out)
+	if bytes.Equal(out, ref) {
+		t.Fatal("Split(0) and Split(1) produced the same output")
+	}
+
+	for i, split := range []func(){
+		func() { r.Split(math.MaxUint64) },
+		func() { r.Split(0).Split(0) },
+	} {
+		func() {
+			defer func() {
+				if err := recover(); err == nil {
+					t.Errorf("Test %d: expected Split to panic but it succeeded", i)
+				}
+			}()
+			split()
+		}()
+	}
+}
+
+func benchmarkUint64(b *testing.B, newRand func([]byte, int) (*Rand, error)) {
+	r, err := newRand(make([]byte, SeedSize), 8)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Uint64()
+	}
+}
+
+func BenchmarkUint64(b *testing.B)               { benchmarkUint64(b, New) }
+func BenchmarkUint64FastKeyErasure(b *testing.B) { benchmarkUint64(b, NewFastKeyErasure) }
+
+var jumpVectors = []struct {
+	rounds int
+	blocks uint64
+	output []byte
+}{
+	{
+		8, 1000,
+		fromHex("c441fac3a797813dde2c262afddd4509a34a7af49e45249d9ed2d563dc58b267b7422aa8d2290302d5585b52756eb975ea59853f9aef34dd520e4bc0425338fd"),
+	},
+}
+
+var splitVectors = []struct {
+	rounds int
+	index  uint64
+	output []byte
+}{
+	{
+		8, 0,
+		fromHex("4fc71deff9d20ebd4138c59256ba3691b887ee56abd2af1452b467a5a461b5392c7e782869b3818dc0523cc7fe65cf01aebe8bd3d7693b9c22432b787f2c26ff"),
+	},
+	{
+		20, 7,
+		fromHex("776840890c48264c63d7ff235d1536c44906f5d7dbeb1dc0927b9b2b889d9e2b"),
+	},
+}
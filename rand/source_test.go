@@ -0,0 +1,33 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// +build go1.22
+
+package rand
+
+import (
+	mrand "math/rand/v2"
+	"testing"
+)
+
+var _ mrand.Source = (*Rand)(nil)
+
+func TestSource(t *testing.T) {
+	seed := make([]byte, SeedSize)
+	r0, err := New(seed, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, err := New(seed, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := mrand.New(r0), mrand.New(r1)
+	for i := 0; i < 1000; i++ {
+		if x, y := a.IntN(1000), b.IntN(1000); x != y {
+			t.Fatalf("Iteration %d: generators with the same seed returned %d and %d", i, x, y)
+		}
+	}
+}
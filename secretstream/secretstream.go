@@ -0,0 +1,293 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// Package secretstream implements libsodium's
+// crypto_secretstream_xchacha20poly1305 construction.
+//
+// A stream encrypts a sequence of messages under one key. The sender
+// generates a random header and derives the stream key from the key and
+// the first 128 bits of the header using HChaCha20. Every message is
+// encrypted with ChaCha20 (96 bit nonce) and authenticated with Poly1305
+// together with a tag. The tag is encrypted as well and tells the receiver
+// whether the message ends a set of messages (TagPush), whether the key
+// is changed (TagRekey) or whether the message is the last one (TagFinal).
+// The nonce depends on all previous messages - so reordered, dropped or
+// duplicated messages are detected.
+//
+// The encrypted messages are byte-for-byte compatible to libsodium's
+// crypto_secretstream_xchacha20poly1305_push and _pull.
+package secretstream // import "github.com/aead/chacha20/secretstream"
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/aead/chacha20/chacha"
+	"github.com/aead/chacha20/poly1305"
+)
+
+const (
+	// KeySize is the size of the key in bytes.
+	KeySize = chacha.KeySize
+
+	// HeaderSize is the size of the stream header in bytes.
+	HeaderSize = chacha.XNonceSize
+
+	// Overhead is the number of bytes added to every message -
+	// the encrypted tag and the Poly1305 authenticator.
+	Overhead = 1 + poly1305.TagSize
+
+	// MaxMessageSize is the max. size of a single message in bytes.
+	MaxMessageSize = 64 * ((1 << 32) - 2)
+)
+
+// A Tag is attached to every message of a stream.
+type Tag byte
+
+const (
+	// TagMessage is the tag of a regular message.
+	TagMessage Tag = 0
+
+	// TagPush marks the end of a set of messages - e.g. the end
+	// of a file within the stream.
+	TagPush Tag = 1
+
+	// TagRekey changes the key after the message is processed.
+	TagRekey Tag = 2
+
+	// TagFinal marks the last message of the stream. It
+	// implies TagPush and TagRekey.
+	TagFinal = TagPush | TagRekey
+)
+
+var (
+	errKeySize    = errors.New("secretstream: bad key length")
+	errHeaderSize = errors.New("secretstream: bad header length")
+	errAuthFailed = errors.New("secretstream: message authentication failed")
+)
+
+// state is the state shared by the sender and the receiver.
+type state struct {
+	key     [32]byte
+	nonce   [chacha.INonceSize]byte // the 32 bit counter followed by the 64 bit inonce
+	cipher  *chacha.Cipher
+	polyKey [64]byte
+	block   [64]byte
+}
+
+func (s *state) init(header, key []byte) error {
+	if len(key) != KeySize {
+		return errKeySize
+	}
+	if len(header) != HeaderSize {
+		return errHeaderSize
+	}
+	var k [32]byte
+	var hNonce [16]byte
+	copy(k[:], key)
+	copy(hNonce[:], header)
+	chacha.HChaCha20(&(s.key), &hNonce, &k)
+	copy(s.nonce[4:], header[16:])
+	s.resetCounter()
+
+	c, err := chacha.NewCipher(s.nonce[:], s.key[:], 20)
+	if err != nil {
+		return err
+	}
+	s.cipher = c
+	for i := range k {
+		k[i] = 0
+	}
+	return nil
+}
+
+// authenticate computes the Poly1305 authenticator of the encrypted
+// tag block and the ciphertext. It expects that the keystream
+// of s.cipher is at block 2.
+func (s *state) authenticate(mac *[poly1305.TagSize]byte, ciphertext, additionalData []byte) {
+	var pad [16]byte
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[0:], uint64(len(additionalData)))
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(s.block)+len(ciphertext)))
+
+	var polyKey [32]byte
+	copy(polyKey[:], s.polyKey[:])
+	h := poly1305.New(&polyKey)
+	h.Write(additionalData)
+	if n := len(additionalData) % 16; n > 0 {
+		h.Write(pad[n:])
+	}
+	h.Write(s.block[:])
+	h.Write(ciphertext)
+	// libsodium computes the padding as (0x10 - 64 + mlen) & 0xf - so
+	// len(ciphertext) % 16 zero bytes are written instead of the number
+	// of bytes needed to reach a multiple of 16.
+	h.Write(pad[:len(ciphertext)%16])
+	h.Write(lengths[:])
+	h.Sum(mac[:0])
+}
+
+// next updates the nonce after processing a message with the
+// given tag and authenticator and changes the key if necessary.
+func (s *state) next(tag Tag, mac *[poly1305.TagSize]byte) {
+	for i := range s.nonce[4:] {
+		s.nonce[4+i] ^= mac[i]
+	}
+	ctr := binary.LittleEndian.Uint32(s.nonce[:]) + 1
+	binary.LittleEndian.PutUint32(s.nonce[:], ctr)
+	if tag&TagRekey != 0 || ctr == 0 {
+		s.rekey()
+	}
+}
+
+// rekey replaces the key and the inonce with the encrypted key
+// and inonce and resets the counter.
+func (s *state) rekey() {
+	var buf [32 + 8]byte
+	copy(buf[:], s.key[:])
+	copy(buf[32:], s.nonce[4:])
+	chacha.XORKeyStream(buf[:], buf[:], s.nonce[:], s.key[:], 20)
+	copy(s.key[:], buf[:])
+	copy(s.nonce[4:], buf[32:])
+	s.resetCounter()
+	for i := range buf {
+		buf[i] = 0
+	}
+}
+
+func (s *state) resetCounter() { binary.LittleEndian.PutUint32(s.nonce[:], 1) }
+
+// setup resets the cipher to the current key and nonce and
+// generates the Poly1305 key (keystream block 0).
+func (s *state) setup() {
+	if err := s.cipher.Reset(s.nonce[:], s.key[:]); err != nil {
+		panic(err)
+	}
+	s.cipher.KeyStream(s.polyKey[:])
+}
+
+// PushStream encrypts the messages of a stream.
+type PushStream struct {
+	state
+}
+
+// NewPushStream returns a new *secretstream.PushStream encrypting messages
+// with the given key and the random header. The header must be sent to the
+// receiver before the first message. The key must be 256 bits long -
+// otherwise a non-nil error is returned.
+func NewPushStream(key []byte) (s *PushStream, header []byte, err error) {
+	header = make([]byte, HeaderSize)
+	if _, err = io.ReadFull(rand.Reader, header); err != nil {
+		return nil, nil, err
+	}
+	s = new(PushStream)
+	if err = s.init(header, key); err != nil {
+		return nil, nil, err
+	}
+	return s, header, nil
+}
+
+// Push encrypts and authenticates the message and the tag, authenticates
+// the additional data and appends the result to dst, returning the updated
+// slice. The returned ciphertext is len(message) + Overhead bytes long.
+// The message and dst must not overlap.
+// Push panics if the message is larger than MaxMessageSize.
+func (s *PushStream) Push(dst, message, additionalData []byte, tag Tag) []byte {
+	if uint64(len(message)) > MaxMessageSize {
+		panic("secretstream: message is too large")
+	}
+	ret, out := sliceForAppend(dst, len(message)+Overhead)
+	ciphertext, mac := out[1:1+len(message)], out[1+len(message):]
+
+	s.setup()
+	for i := range s.block {
+		s.block[i] = 0
+	}
+	s.block[0] = byte(tag)
+	s.cipher.XORKeyStream(s.block[:], s.block[:])
+	s.cipher.XORKeyStream(ciphertext, message)
+	out[0] = s.block[0]
+
+	var sum [poly1305.TagSize]byte
+	s.authenticate(&sum, ciphertext, additionalData)
+	copy(mac, sum[:])
+	s.next(tag, &sum)
+	return ret
+}
+
+// Rekey changes the key explicitly. The receiver must call
+// Rekey at the same position of the stream.
+func (s *PushStream) Rekey() { s.rekey() }
+
+// PullStream decrypts the messages of a stream.
+type PullStream struct {
+	state
+}
+
+// NewPullStream returns a new *secretstream.PullStream decrypting messages
+// with the given key and the header generated by the sender. The key must
+// be 256 bits long and the header HeaderSize bytes - otherwise a non-nil
+// error is returned.
+func NewPullStream(header, key []byte) (*PullStream, error) {
+	s := new(PullStream)
+	if err := s.init(header, key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Pull verifies and decrypts the ciphertext produced by Push, appends the
+// message to dst and returns the updated slice and the tag of the message.
+// The message is only decrypted if the ciphertext and the additional data
+// are authentic - otherwise a non-nil error is returned and the stream
+// state remains unchanged. The ciphertext and dst must not overlap.
+func (s *PullStream) Pull(dst, ciphertext, additionalData []byte) ([]byte, Tag, error) {
+	if len(ciphertext) < Overhead || uint64(len(ciphertext)-Overhead) > MaxMessageSize {
+		return nil, 0, errAuthFailed
+	}
+	mac := ciphertext[len(ciphertext)-poly1305.TagSize:]
+	encTag, ciphertext := ciphertext[0], ciphertext[1:len(ciphertext)-poly1305.TagSize]
+
+	s.setup()
+	for i := range s.block {
+		s.block[i] = 0
+	}
+	s.block[0] = encTag
+	s.cipher.XORKeyStream(s.block[:], s.block[:])
+	tag := Tag(s.block[0])
+	s.block[0] = encTag
+
+	var sum [poly1305.TagSize]byte
+	s.authenticate(&sum, ciphertext, additionalData)
+	if subtle.ConstantTimeCompare(sum[:], mac) != 1 {
+		return nil, 0, errAuthFailed
+	}
+
+	ret, message := sliceForAppend(dst, len(ciphertext))
+	s.cipher.XORKeyStream(message, ciphertext)
+	s.next(tag, &sum)
+	return ret, tag, nil
+}
+
+// Rekey changes the key explicitly. It must be called
+// at the same position of the stream as by the sender.
+func (s *PullStream) Rekey() { s.rekey() }
+
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes and a
+// second slice that aliases into it and contains only the extra bytes. If the
+// original slice has sufficient capacity then no allocation is performed.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package secretstream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func toHex(bits []byte) string {
+	return hex.EncodeToString(bits)
+}
+
+func fromHex(bits string) []byte {
+	b, err := hex.DecodeString(bits)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestVectors(t *testing.T) {
+	for i, v := range vectors {
+		var push PushStream
+		if err := push.init(v.header, v.key); err != nil {
+			t.Fatalf("Test %d: Failed to create PushStream: %v", i, err)
+		}
+		pull, err := NewPullStream(v.header, v.key)
+		if err != nil {
+			t.Fatalf("Test %d: Failed to create PullStream: %v", i, err)
+		}
+		if v.counter != 0 {
+			binary.LittleEndian.PutUint32(push.nonce[:], v.counter)
+			binary.LittleEndian.PutUint32(pull.nonce[:], v.counter)
+		}
+
+		for j, s := range v.steps {
+			if s.rekey {
+				push.Rekey()
+				pull.Rekey()
+				continue
+			}
+			ciphertext := push.Push(nil, s.message, s.ad, s.tag)
+			if !bytes.Equal(ciphertext, s.ciphertext) {
+				t.Fatalf("Test %d: step %d: ciphertext mismatch:\n \t got:  %s\n \t want: %s", i, j, toHex(ciphertext), toHex(s.ciphertext))
+			}
+
+			message, tag, err := pull.Pull(nil, s.ciphertext, s.ad)
+			if err != nil {
+				t.Fatalf("Test %d: step %d: Pull failed: %v", i, j, err)
+			}
+			if tag != s.tag {
+				t.Fatalf("Test %d: step %d: tag mismatch: got %d - want %d", i, j, tag, s.tag)
+			}
+			if !bytes.Equal(message, s.message) {
+				t.Fatalf("Test %d: step %d: message mismatch:\n \t got:  %s\n \t want: %s", i, j, toHex(message), toHex(s.message))
+			}
+		}
+	}
+}
+
+func TestPull(t *testing.T) {
+	key := make([]byte, KeySize)
+	push, header, err := NewPushStream(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := [][]byte{
+		push.Push(nil, []byte("first"), nil, TagMessage),
+		push.Push(nil, []byte("second"), []byte("ad"), TagMessage),
+		push.Push(nil, []byte("third"), nil, TagFinal),
+	}
+
+	flip := func(b []byte, i int) []byte {
+		b = append([]byte(nil), b...)
+		b[i] ^= 1
+		return b
+	}
+	for i, test := range []struct {
+		ciphertext, ad []byte
+	}{
+		{ciphertext: msgs[1], ad: []byte("ad")}, // dropped message
+		{ciphertext: msgs[0][:Overhead-1]},
+		{ciphertext: flip(msgs[0], 0)}, // tag
+		{ciphertext: flip(msgs[0], 1)},
+		{ciphertext: flip(msgs[0], len(msgs[0])-1)},
+		{ciphertext: msgs[0], ad: []byte("ad")},
+	} {
+		pull, err := NewPullStream(header, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = pull.Pull(nil, test.ciphertext, test.ad); err != errAuthFailed {
+			t.Errorf("Test %d: Pull returned %v - want %v", i, err, errAuthFailed)
+		}
+
+		// A failed Pull must not change the state.
+		for j, ad := range [][]byte{nil, []byte("ad"), nil} {
+			if _, _, err = pull.Pull(nil, msgs[j], ad); err != nil {
+				t.Fatalf("Test %d: Pull failed after a rejected message: %v", i, err)
+			}
+		}
+	}
+
+	if _, err = NewPullStream(header[:HeaderSize-1], key); err != errHeaderSize {
+		t.Errorf("NewPullStream returned %v - want %v", err, errHeaderSize)
+	}
+	if _, err = NewPullStream(header, key[:16]); err != errKeySize {
+		t.Errorf("NewPullStream returned %v - want %v", err, errKeySize)
+	}
+	if _, _, err = NewPushStream(key[:16]); err != errKeySize {
+		t.Errorf("NewPushStream returned %v - want %v", err, errKeySize)
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	s, _, err := NewPushStream(make([]byte, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A message of MaxMessageSize bytes starts at block 2 and ends with the
+	// last block of the keystream period. Instead of en/decrypting 256 GiB
+	// the counter is moved to the last block of such a message.
+	lastBlock := uint64(2 + MaxMessageSize/64 - 1)
+	if lastBlock != math.MaxUint32 {
+		t.Fatalf("The last block of a MaxMessageSize message is %d - want %d", lastBlock, uint64(math.MaxUint32))
+	}
+	s.setup()
+	s.cipher.SetCounter(lastBlock)
+	block := make([]byte, 64)
+	if err = s.cipher.XORKeyStreamChecked(block, block); err != nil {
+		t.Fatalf("En/decrypting the last block of a MaxMessageSize message failed: %v", err)
+	}
+	if err = s.cipher.XORKeyStreamChecked(block[:1], block[:1]); err == nil {
+		t.Fatal("En/decrypting more than MaxMessageSize bytes succeeded")
+	}
+}
+
+func benchmarkPush(b *testing.B, size int) {
+	s, _, err := NewPushStream(make([]byte, KeySize))
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := make([]byte, size)
+	buf := make([]byte, 0, size+Overhead)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Push(buf, msg, nil, TagMessage)
+	}
+}
+
+func BenchmarkPush_64(b *testing.B) { benchmarkPush(b, 64) }
+func BenchmarkPush_1K(b *testing.B) { benchmarkPush(b, 1024) }
+func BenchmarkPush_8K(b *testing.B) { benchmarkPush(b, 8*1024) }
+
+type step struct {
+	rekey      bool // call Rekey instead of Push and Pull
+	tag        Tag
+	ad         []byte
+	message    []byte
+	ciphertext []byte
+}
+
+type vector struct {
+	key, header []byte
+	counter     uint32 // if not 0, the counter is set to this value after initialization
+	steps       []step
+}
+
+// The vectors are generated using libsodium's crypto_secretstream_xchacha20poly1305
+// functions. The second vector sets the counter of the libsodium state such that the
+// key is changed automatically after the first message.
+var vectors = []vector{
+	{
+		key:    fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"),
+		header: fromHex("6465666768696a6b6c6d6e6f707172737475767778797a7b"),
+		steps: []step{
+			{
+				tag:        TagMessage,
+				ciphertext: fromHex("5fd5ec9f27982984c6c795bc5f0c7c4685"),
+			},
+			{
+				tag:        TagMessage,
+				message:    fromHex("48656c6c6f"),
+				ciphertext: fromHex("4bc96c67f3b2caff0efc4725486a2903447b93299de7"),
+			},
+			{
+				tag:        TagPush,
+				ad:         fromHex("686561646572"),
+				message:    fromHex("7365636f6e64206d657373616765"),
+				ciphertext: fromHex("8fe26743924711093de7990953911031be8a62c68652e7b0b24334c31148ed"),
+			},
+			{
+				tag: TagMessage,
+				message: fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f" +
+					"303132333435363738393a3b3c3d3e3f404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f" +
+					"60616263"),
+				ciphertext: fromHex("ab4fad566b4a5a3f4dbca7b89ecbae13df6f4614df3ff2d725d6f59ca688ee6759c2a6e0d958581b8e3cc222cd528d17" +
+					"22313ea0b44842e8c406f33feea4e3a4212871ff1f45f733e8e1d7e8e11a98ea72f7dbef73f9aaca62a2faa4c2e66513" +
+					"0ee79f5d8a33f861d1eeee41aec02480eccc140350"),
+			},
+			{
+				tag:        TagRekey,
+				message:    fromHex("72656b65792061667465722074686973"),
+				ciphertext: fromHex("c9337a829ac90a1b72226f8de7735197ee27e6e08491a712b56c9396e6d9ddacab"),
+			},
+			{
+				tag:        TagMessage,
+				ad:         fromHex("6164"),
+				message:    fromHex("61667465722072656b6579"),
+				ciphertext: fromHex("55dcf199335e411a6acc731411150d9cd856223d20c86b86b78cdadf"),
+			},
+			{rekey: true},
+			{
+				tag:        TagMessage,
+				message:    fromHex("6166746572206d616e75616c2072656b6579"),
+				ciphertext: fromHex("e896ea3d6a59af62eab25df59d61fa0d2e8f3f86b761daa70385c39b772350fab7585f"),
+			},
+			{
+				tag:        TagFinal,
+				message:    fromHex("74686520656e64"),
+				ciphertext: fromHex("ec43f66a884c33c5370160e862cd74822e48d1f6d3320d2b"),
+			},
+		},
+	},
+	{
+		key:     fromHex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0efeeedecebeae9e8e7e6e5e4e3e2e1e0"),
+		header:  fromHex("00070e151c232a31383f464d545b626970777e858c939aa1"),
+		counter: 0xffffffff,
+		steps: []step{
+			{
+				tag:        TagMessage,
+				message:    fromHex("6265666f72652074686520636f756e746572207772617073"),
+				ciphertext: fromHex("ae6201f4c63aa4ea36820e7a43dae46b0bf8ae54442887a597166d0ade173ee8458f79494315bb9d9e"),
+			},
+			{
+				tag:        TagMessage,
+				message:    fromHex("61667465722074686520636f756e7465722077726170706564"),
+				ciphertext: fromHex("d1b9b4508789ba24113fcd793424e5068a4638d82949aa5a1c5acbf6a3f5b6f89220533d05596ed80fed"),
+			},
+			{
+				tag:        TagFinal,
+				ciphertext: fromHex("bcc02d522514b069e7873a0e682f92efa2"),
+			},
+		},
+	},
+}
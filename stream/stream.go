@@ -0,0 +1,266 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+// Package stream implements the STREAM construction for ChaCha20-Poly1305.
+//
+// STREAM splits a plaintext into chunks of a fixed size and encrypts every
+// chunk with the ChaCha20-Poly1305 AEAD specified in RFC 8439. The nonce of
+// a chunk is the 88 bit big-endian chunk counter followed by one byte which
+// is 1 for the final chunk and 0 otherwise. So reordered, dropped and
+// duplicated chunks as well as truncated streams are detected.
+// Only the final chunk may be shorter than the chunk size and it is empty
+// only if the entire plaintext is empty.
+//
+// The key must be unique for every stream - for example derived from a
+// long-term key and a random salt. The construction is specified in
+// https://eprint.iacr.org/2015/189 and used by the age file format.
+package stream // import "github.com/aead/chacha20/stream"
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	"github.com/aead/chacha20"
+	"github.com/aead/chacha20/chacha"
+)
+
+const (
+	// KeySize is the size of the key in bytes.
+	KeySize = chacha.KeySize
+
+	// TagSize is the size of the authentication tag appended to every chunk.
+	TagSize = chacha20.TagSize
+
+	// DefaultChunkSize is the plaintext size of all but the final chunk
+	// used by age.
+	DefaultChunkSize = 64 * 1024
+
+	maxChunkSize = 1 << 30
+)
+
+var (
+	errKeySize       = errors.New("stream: bad key length")
+	errChunkSize     = errors.New("stream: bad chunk size")
+	errAuthFailed    = errors.New("stream: message authentication failed")
+	errFinalChunk    = errors.New("stream: empty final chunk")
+	errTrailingData  = errors.New("stream: data after the final chunk")
+	errClosed        = errors.New("stream: write to closed writer")
+	errTooManyChunks = errors.New("stream: too many chunks")
+)
+
+// Writer encrypts a plaintext stream and writes the sequence
+// of chunks to an underlying io.Writer.
+type Writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	nonce [chacha.INonceSize]byte
+	buf   []byte
+	size  int
+	err   error
+}
+
+// NewWriter returns a new *stream.Writer encrypting chunks of chunkSize
+// plaintext bytes with the given key and writing them to w. The key must
+// be 256 bits long and chunkSize must be positive - otherwise a non-nil
+// error is returned.
+// The final chunk is written when Close is called.
+func NewWriter(w io.Writer, key []byte, chunkSize int) (*Writer, error) {
+	aead, err := newAEAD(key, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, chunkSize+TagSize),
+		size: chunkSize,
+	}, nil
+}
+
+// Write encrypts p and writes all completed chunks to the underlying
+// io.Writer. A chunk is only written once it is known that it is not
+// the final chunk - so up to chunkSize bytes are buffered.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	for len(p) > 0 {
+		if len(w.buf) == w.size {
+			if err = w.flush(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(w.buf[len(w.buf):w.size], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+// Close encrypts and writes the final chunk. It doesn't close the
+// underlying io.Writer. Once Close has been called Write returns an
+// error.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		if w.err == errClosed {
+			return nil
+		}
+		return w.err
+	}
+	if err := w.flush(true); err != nil {
+		return err
+	}
+	w.err = errClosed
+	return nil
+}
+
+func (w *Writer) flush(final bool) error {
+	if final {
+		w.nonce[len(w.nonce)-1] = 1
+	}
+	chunk := w.aead.Seal(w.buf[:0], w.nonce[:], w.buf, nil)
+	if _, err := w.w.Write(chunk); err != nil {
+		w.err = err
+		return err
+	}
+	w.buf = w.buf[:0]
+	if err := incCounter(&(w.nonce)); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Reader decrypts and verifies a sequence of chunks read from an
+// underlying io.Reader. No plaintext of a chunk is returned before
+// the authentication tag of the chunk has been verified.
+type Reader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	nonce [chacha.INonceSize]byte
+	buf   []byte
+
+	plaintext []byte // the verified but unread plaintext
+	err       error
+}
+
+// NewReader returns a new *stream.Reader decrypting chunks of chunkSize
+// plaintext bytes read from r with the given key. The key and the chunk
+// size must match the values passed to NewWriter - otherwise a non-nil
+// error is returned or reading fails.
+func NewReader(r io.Reader, key []byte, chunkSize int) (*Reader, error) {
+	aead, err := newAEAD(key, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		r:    r,
+		aead: aead,
+		buf:  make([]byte, chunkSize+TagSize),
+	}, nil
+}
+
+// Read reads up to len(p) bytes of verified plaintext. It returns io.EOF
+// after the final chunk has been read and a non-nil error if the stream
+// has been modified or truncated.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	for len(r.plaintext) == 0 && r.err == nil {
+		r.err = r.readChunk()
+	}
+	if len(r.plaintext) == 0 {
+		return 0, r.err
+	}
+	n = copy(p, r.plaintext)
+	r.plaintext = r.plaintext[n:]
+	return n, nil
+}
+
+// readChunk reads, verifies and decrypts the next chunk. A chunk shorter
+// than a complete chunk must be the final chunk. A complete chunk is the
+// final chunk if it is authentic as final chunk - in this case the
+// underlying io.Reader must not return any data after it.
+// readChunk returns io.EOF if the chunk is the final chunk.
+func (r *Reader) readChunk() error {
+	n, err := io.ReadFull(r.r, r.buf)
+	final := false
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+		final = true
+	case io.EOF:
+		return io.ErrUnexpectedEOF
+	default:
+		return err
+	}
+	if n < TagSize {
+		return io.ErrUnexpectedEOF
+	}
+	if final && n == TagSize && !isFirst(&(r.nonce)) {
+		return errFinalChunk
+	}
+
+	if final {
+		r.nonce[len(r.nonce)-1] = 1
+	}
+	plaintext, err := r.aead.Open(r.buf[:0], r.nonce[:], r.buf[:n], nil)
+	if err != nil && !final {
+		final = true
+		r.nonce[len(r.nonce)-1] = 1
+		plaintext, err = r.aead.Open(r.buf[:0], r.nonce[:], r.buf[:n], nil)
+	}
+	if err != nil {
+		return errAuthFailed
+	}
+	if err = incCounter(&(r.nonce)); err != nil {
+		return err
+	}
+	r.plaintext = plaintext
+	if !final {
+		return nil
+	}
+
+	var next [1]byte
+	switch _, err = io.ReadFull(r.r, next[:]); err {
+	case nil:
+		return errTrailingData
+	case io.EOF:
+		return io.EOF
+	default:
+		return err
+	}
+}
+
+func newAEAD(key []byte, chunkSize int) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errKeySize
+	}
+	if chunkSize <= 0 || chunkSize > maxChunkSize {
+		return nil, errChunkSize
+	}
+	return chacha20.NewAEAD(key)
+}
+
+// incCounter increments the chunk counter in the first
+// 11 bytes of the nonce.
+func incCounter(nonce *[chacha.INonceSize]byte) error {
+	for i := len(nonce) - 2; i >= 0; i-- {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return nil
+		}
+	}
+	return errTooManyChunks
+}
+
+// isFirst returns true if the nonce belongs to the first chunk.
+func isFirst(nonce *[chacha.INonceSize]byte) bool {
+	for _, v := range nonce[:len(nonce)-1] {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2018 Andreas Auernhammer. All rights reserved.
+// Use of this source code is governed by a license that can be
+// found in the LICENSE file.
+
+package stream
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aead/chacha20"
+)
+
+func toHex(bits []byte) string {
+	return hex.EncodeToString(bits)
+}
+
+func fromHex(bits string) []byte {
+	b, err := hex.DecodeString(bits)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func encrypt(t *testing.T, plaintext, key []byte, chunkSize, writeSize int) []byte {
+	var ciphertext bytes.Buffer
+	w, err := NewWriter(&ciphertext, key, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := plaintext; len(p) > 0; {
+		n := writeSize
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err = w.Write(p[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		p = p[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return ciphertext.Bytes()
+}
+
+func decrypt(ciphertext, key []byte, chunkSize int) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(ciphertext), key, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func TestVectors(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	for i, v := range vectors {
+		ciphertext := encrypt(t, v.plaintext, key, v.chunkSize, 7)
+		if !bytes.Equal(ciphertext, v.ciphertext) {
+			t.Errorf("Test %d: ciphertext mismatch:\n \t got:  %s\n \t want: %s", i, toHex(ciphertext), toHex(v.ciphertext))
+		}
+		plaintext, err := decrypt(v.ciphertext, key, v.chunkSize)
+		if err != nil {
+			t.Errorf("Test %d: decryption failed: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, v.plaintext) {
+			t.Errorf("Test %d: plaintext mismatch:\n \t got:  %s\n \t want: %s", i, toHex(plaintext), toHex(v.plaintext))
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	key := make([]byte, KeySize)
+	const chunkSize = 64
+	for _, size := range []int{0, 1, 63, 64, 65, 127, 128, 129, 1000} {
+		plaintext := make([]byte, size)
+		for i := range plaintext {
+			plaintext[i] = byte(i)
+		}
+		for _, writeSize := range []int{1, 13, 64, 4096} {
+			ciphertext := encrypt(t, plaintext, key, chunkSize, writeSize)
+			chunks := (size + chunkSize - 1) / chunkSize
+			if chunks == 0 {
+				chunks = 1
+			}
+			if len(ciphertext) != size+chunks*TagSize {
+				t.Fatalf("Size %d: ciphertext has %d bytes - want %d", size, len(ciphertext), size+chunks*TagSize)
+			}
+			decrypted, err := decrypt(ciphertext, key, chunkSize)
+			if err != nil {
+				t.Fatalf("Size %d: write size %d: decryption failed: %v", size, writeSize, err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatalf("Size %d: write size %d: plaintext mismatch", size, writeSize)
+			}
+		}
+	}
+}
+
+func TestModified(t *testing.T) {
+	key := make([]byte, KeySize)
+	const chunkSize = 64
+	const encChunkSize = chunkSize + TagSize
+	plaintext := make([]byte, 3*chunkSize+10)
+	ciphertext := encrypt(t, plaintext, key, chunkSize, len(plaintext))
+	empty := encrypt(t, nil, key, chunkSize, 1)
+
+	// A stream with an empty final chunk after a full chunk.
+	// Such a stream is never produced by a Writer.
+	aead, err := chacha20.NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	nonce[10], nonce[11] = 1, 1
+	emptyFinal := aead.Seal(append([]byte(nil), ciphertext[:encChunkSize]...), nonce, nil, nil)
+
+	fullFinal := encrypt(t, plaintext[:chunkSize], key, chunkSize, 1)
+
+	flip := func(i int) []byte {
+		b := append([]byte(nil), ciphertext...)
+		b[i] ^= 1
+		return b
+	}
+	swap := func(i, j int) []byte {
+		b := append([]byte(nil), ciphertext...)
+		copy(b[i*encChunkSize:], ciphertext[j*encChunkSize:(j+1)*encChunkSize])
+		copy(b[j*encChunkSize:], ciphertext[i*encChunkSize:(i+1)*encChunkSize])
+		return b
+	}
+
+	for i, test := range []struct {
+		ciphertext []byte
+		verified   int // the number of plaintext bytes which may be returned
+	}{
+		{ciphertext: nil, verified: 0},
+		{ciphertext: empty[:TagSize-1], verified: 0},
+		{ciphertext: ciphertext[:encChunkSize], verified: chunkSize},
+		{ciphertext: ciphertext[:3*encChunkSize], verified: 3 * chunkSize},
+		{ciphertext: ciphertext[:len(ciphertext)-1], verified: 3 * chunkSize},
+		{ciphertext: append(ciphertext[:len(ciphertext):len(ciphertext)], 0), verified: 3 * chunkSize},
+		{ciphertext: append(ciphertext[:len(ciphertext):len(ciphertext)], empty...), verified: 3 * chunkSize},
+		{ciphertext: flip(0), verified: 0},
+		{ciphertext: flip(encChunkSize - 1), verified: 0},
+		{ciphertext: flip(encChunkSize + 10), verified: chunkSize},
+		{ciphertext: flip(len(ciphertext) - 1), verified: 3 * chunkSize},
+		{ciphertext: swap(0, 1), verified: 0},
+		{ciphertext: swap(1, 2), verified: chunkSize},
+		{ciphertext: emptyFinal, verified: chunkSize},
+		{ciphertext: append(fullFinal, 0), verified: chunkSize},
+	} {
+		r, err := NewReader(bytes.NewReader(test.ciphertext), key, chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := io.Copy(ioutil.Discard, r)
+		if err == nil {
+			t.Errorf("Test %d: Reader accepted a modified stream", i)
+		}
+		if n > int64(test.verified) {
+			t.Errorf("Test %d: Reader returned %d bytes of unverified plaintext", i, n-int64(test.verified))
+		}
+		if _, err2 := r.Read(make([]byte, 1)); err2 != err {
+			t.Errorf("Test %d: Read returned %v after %v", i, err2, err)
+		}
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	w, err := NewWriter(ioutil.Discard, make([]byte, KeySize), DefaultChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Errorf("Second Close returned %v", err)
+	}
+	if _, err = w.Write([]byte{0}); err != errClosed {
+		t.Errorf("Write returned %v - want %v", err, errClosed)
+	}
+}
+
+func TestBadArguments(t *testing.T) {
+	if _, err := NewWriter(ioutil.Discard, make([]byte, 16), DefaultChunkSize); err != errKeySize {
+		t.Errorf("NewWriter returned %v - want %v", err, errKeySize)
+	}
+	if _, err := NewReader(bytes.NewReader(nil), make([]byte, 16), DefaultChunkSize); err != errKeySize {
+		t.Errorf("NewReader returned %v - want %v", err, errKeySize)
+	}
+	for _, size := range []int{-1, 0, maxChunkSize + 1} {
+		if _, err := NewWriter(ioutil.Discard, make([]byte, KeySize), size); err != errChunkSize {
+			t.Errorf("NewWriter returned %v - want %v", err, errChunkSize)
+		}
+		if _, err := NewReader(bytes.NewReader(nil), make([]byte, KeySize), size); err != errChunkSize {
+			t.Errorf("NewReader returned %v - want %v", err, errChunkSize)
+		}
+	}
+}
+
+func benchmarkWriter(b *testing.B, size int) {
+	w, err := NewWriter(ioutil.Discard, make([]byte, KeySize), DefaultChunkSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, size)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Write(buf)
+	}
+}
+
+func benchmarkReader(b *testing.B, size int) {
+	key := make([]byte, KeySize)
+	plaintext := make([]byte, size)
+	var ciphertext bytes.Buffer
+	w, _ := NewWriter(&ciphertext, key, DefaultChunkSize)
+	w.Write(plaintext)
+	w.Close()
+
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, _ := NewReader(bytes.NewReader(ciphertext.Bytes()), key, DefaultChunkSize)
+		if _, err := io.ReadFull(r, plaintext); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriter_1K(b *testing.B)  { benchmarkWriter(b, 1024) }
+func BenchmarkWriter_64K(b *testing.B) { benchmarkWriter(b, 64*1024) }
+func BenchmarkReader_1M(b *testing.B)  { benchmarkReader(b, 1024*1024) }
+
+type vector struct {
+	plaintext  []byte
+	chunkSize  int
+	ciphertext []byte
+}
+
+// The vectors are generated using libsodium's crypto_aead_chacha20poly1305_ietf.
+var vectors = []vector{
+	{
+		plaintext:  nil,
+		chunkSize:  32,
+		ciphertext: fromHex("fa0e145e8775eb78c274755606de74fb"),
+	},
+	{
+		plaintext: []byte("The STREAM construction splits the plaintext into chunks."),
+		chunkSize: 32,
+		ciphertext: fromHex("4cd02711feb2f494522c7c02c02d3d538ac4908188c2357c9f88907b5e46552830c1f89b51fd4d77bbf0891e8324ad4e" +
+			"692622dab5210d22ab18d46fae6d4166b9a84a2643bae739b99dbef03acaa713414e06ec40ac6e44e2"),
+	},
+}